@@ -0,0 +1,207 @@
+/*
+ * OIDC² - Identity Certification Token Endpoint
+ *
+ * Endpoint for OpenID Connect's Identity Certification Token endpoint.
+ *
+ * API version: 0.5.0
+ */
+package ict
+
+import (
+	"context"
+	"crypto"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"golang.org/x/sync/singleflight"
+
+	"ict/verify"
+)
+
+// Access token validator which is used instead of token introspection, if configured.
+var appAccessTokenValidator *AccessTokenValidator
+
+// Minimum period between two requests of the OpenID Provider's JWK set.
+const jwksMinRefreshInterval = 10 * time.Second
+
+// Maximum duration of a shared request of the OpenID Provider's JWK set, which is not limited by the request deadline.
+const jwksRefreshTimeout = 10 * time.Second
+
+// JwksCache caches the OpenID Provider's JSON Web Key Set and refreshes it if a key ID is unknown.
+// The mutex is only held to read or replace the cached JWK set, so a slow refresh does not block known keys.
+type JwksCache struct {
+	uri                string
+	minRefreshInterval time.Duration
+	mutex              sync.Mutex
+	jwks               map[string]interface{}
+	refreshedAt        time.Time
+	// Concurrent lookups of unknown key IDs share a single refresh.
+	refreshes singleflight.Group
+}
+
+func NewJwksCache(uri string, minRefreshInterval time.Duration) *JwksCache {
+	return &JwksCache{
+		uri:                uri,
+		minRefreshInterval: minRefreshInterval,
+	}
+}
+
+// PublicKey returns the public key with ID keyId to verify a token signed with algorithm alg.
+// If the JWK set is refreshed, the request is aborted when ctx is done.
+func (cache *JwksCache) PublicKey(ctx context.Context, keyId string, alg jwt.SigningMethod) (crypto.PublicKey, error) {
+	publicKey, err, _ := cache.publicKey(ctx, keyId, alg)
+	return publicKey, err
+}
+
+// publicKey is like PublicKey, but the returned bool is true if the JWK set could not be requested.
+func (cache *JwksCache) publicKey(ctx context.Context, keyId string, alg jwt.SigningMethod) (crypto.PublicKey, error, bool) {
+	cache.mutex.Lock()
+	jwks, refreshedAt := cache.jwks, cache.refreshedAt
+	cache.mutex.Unlock()
+
+	// Resolve key from cached JWK set
+	var err error
+	if jwks != nil {
+		var publicKey crypto.PublicKey
+		publicKey, err = verify.JwksPublicKeyResolver(jwks)(keyId, alg)
+		if err == nil {
+			return publicKey, nil, false
+		}
+	}
+
+	// Refresh JWK set, but not more often than the minimum refresh interval
+	if jwks != nil && time.Since(refreshedAt) < cache.minRefreshInterval {
+		return nil, err, false
+	}
+	jwks, err = cache.refresh(ctx)
+	if err != nil {
+		return nil, err, true
+	}
+
+	// Resolve key from refreshed JWK set
+	publicKey, err := verify.JwksPublicKeyResolver(jwks)(keyId, alg)
+	return publicKey, err, false
+}
+
+// refresh requests the JWK set and replaces the cached JWK set.
+// The request is shared by concurrent callers, so it is not cancelled with ctx, but each caller stops waiting when its ctx is done.
+func (cache *JwksCache) refresh(ctx context.Context) (map[string]interface{}, error) {
+	result := cache.refreshes.DoChan("jwks", func() (interface{}, error) {
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), jwksRefreshTimeout)
+		defer cancel()
+		jwks, err := cache.requestJwks(ctx)
+		if err != nil {
+			return nil, err
+		}
+		cache.mutex.Lock()
+		cache.jwks = jwks
+		cache.refreshedAt = time.Now()
+		cache.mutex.Unlock()
+		return jwks, nil
+	})
+	select {
+	case <-ctx.Done():
+		return nil, errors.New("failed to get JWK set from '" + cache.uri + "': " + ctx.Err().Error())
+	case res := <-result:
+		if res.Err != nil {
+			return nil, res.Err
+		}
+		return res.Val.(map[string]interface{}), nil
+	}
+}
+
+// requestJwks requests the JWK set from the OpenID Provider.
+func (cache *JwksCache) requestJwks(ctx context.Context) (map[string]interface{}, error) {
+	res, err := DoUpstreamRequest(ctx, cache.uri, func(ctx context.Context) (*http.Request, error) {
+		return http.NewRequestWithContext(ctx, "GET", cache.uri, nil)
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != 200 {
+		return nil, errors.New("failed to get JWK set from '" + cache.uri + "'. status code: " + fmt.Sprint(res.StatusCode) + ", status: '" + res.Status + "'")
+	}
+
+	var jwks map[string]interface{}
+	if err = json.NewDecoder(res.Body).Decode(&jwks); err != nil {
+		return nil, errors.New("failed to parse JWK set: " + err.Error())
+	}
+	return jwks, nil
+}
+
+// AccessTokenValidator validates JWT access tokens locally with the OpenID Provider's public keys.
+type AccessTokenValidator struct {
+	// Expected issuer of the access tokens.
+	Issuer string
+	// Expected audience of the access tokens. Not checked if empty.
+	Audience string
+	// Public keys of the OpenID Provider.
+	Keys *JwksCache
+}
+
+// Validate verifies the signature, issuer, audience and time constraints of the JWT access token at time now.
+// The claims are returned in the form of a token introspection response.
+// The returned bool is true if the access token was rejected.
+func (validator AccessTokenValidator) Validate(ctx context.Context, accessToken string, now time.Time) (IntrospectionResponse, error, bool) {
+	// Parse token and verify signature
+	var keysErr error
+	parser := jwt.NewParser(jwt.WithValidMethods(SupportedSigningAlgorithms), jwt.WithoutClaimsValidation())
+	claims := jwt.MapClaims{}
+	_, err := parser.ParseWithClaims(accessToken, &claims, func(token *jwt.Token) (interface{}, error) {
+		keyId, err := StringFromJson(token.Header, "kid")
+		if err != nil {
+			return nil, errors.New("key ID not found in header: " + err.Error())
+		}
+		publicKey, err, unavailable := validator.Keys.publicKey(ctx, keyId, token.Method)
+		if unavailable {
+			keysErr = err
+		}
+		return publicKey, err
+	})
+	if keysErr != nil {
+		return IntrospectionResponse{}, keysErr, false
+	}
+	if err != nil {
+		return IntrospectionResponse{}, errors.New("invalid access token: " + err.Error()), true
+	}
+
+	// Verify issuer
+	if !claims.VerifyIssuer(validator.Issuer, true) {
+		return IntrospectionResponse{}, errors.New("invalid access token: expected issuer '" + validator.Issuer + "'"), true
+	}
+
+	// Verify audience
+	if validator.Audience != "" && !claims.VerifyAudience(validator.Audience, true) {
+		return IntrospectionResponse{}, errors.New("invalid access token: expected audience '" + validator.Audience + "'"), true
+	}
+
+	// Convert claims to introspection response
+	claimsJson, err := json.Marshal(claims)
+	if err != nil {
+		return IntrospectionResponse{}, errors.New("failed to encode access token claims: " + err.Error()), false
+	}
+	var introspection IntrospectionResponse
+	if err = json.Unmarshal(claimsJson, &introspection); err != nil {
+		return IntrospectionResponse{}, errors.New("invalid access token: " + err.Error()), true
+	}
+	introspection.Active = true
+	if typ, err := StringFromJson(claims, "typ"); err == nil {
+		introspection.TokenType = typ
+	}
+
+	// Verify time constraints
+	if introspection.ExpiresAt == 0 {
+		return IntrospectionResponse{}, errors.New("invalid access token: expiration time not found"), true
+	}
+	if err = introspection.Validate(now); err != nil {
+		return IntrospectionResponse{}, errors.New("invalid access token: " + err.Error()), true
+	}
+
+	return introspection, nil, false
+}
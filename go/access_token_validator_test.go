@@ -0,0 +1,239 @@
+/*
+ * OIDC² - Identity Certification Token Endpoint
+ *
+ * Endpoint for OpenID Connect's Identity Certification Token endpoint.
+ *
+ * API version: 0.5.0
+ */
+package ict
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+const (
+	testAccessTokenIssuer   = "https://op.example.com/realms/ict"
+	testAccessTokenAudience = "ict"
+)
+
+// testJwksServer serves the JWK set of the OpenID Provider's signing keys and counts the requests.
+type testJwksServer struct {
+	*httptest.Server
+	mutex    sync.Mutex
+	keys     []SigningKey
+	requests atomic.Int32
+}
+
+func newTestJwksServer(t *testing.T, keys ...SigningKey) *testJwksServer {
+	t.Helper()
+	server := &testJwksServer{keys: keys}
+	server.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		server.requests.Add(1)
+		server.mutex.Lock()
+		jwks, err := JwkSetFromSigningKeys(server.keys)
+		server.mutex.Unlock()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(jwks)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// AddKey publishes another key, e.g., after a key rotation of the OpenID Provider.
+func (server *testJwksServer) AddKey(key SigningKey) {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	server.keys = append(server.keys, key)
+}
+
+func newTestSigningKey(t *testing.T) SigningKey {
+	t.Helper()
+	key, err := GenerateSigningKey(jwt.SigningMethodES256)
+	if err != nil {
+		t.Fatalf("failed to generate signing key: %v", err)
+	}
+	return key
+}
+
+// newTestAccessToken signs an access token with valid claims, which are replaced by claims.
+func newTestAccessToken(t *testing.T, key SigningKey, claims jwt.MapClaims) string {
+	t.Helper()
+	now := time.Now()
+	tokenClaims := jwt.MapClaims{
+		"iss": testAccessTokenIssuer,
+		"aud": testAccessTokenAudience,
+		"sub": "alice",
+		"azp": "client",
+		"typ": "Bearer",
+		"iat": now.Unix(),
+		"exp": now.Add(time.Minute).Unix(),
+	}
+	for name, value := range claims {
+		tokenClaims[name] = value
+	}
+	token := jwt.NewWithClaims(key.Algorithm, tokenClaims)
+	token.Header["kid"] = key.KeyId
+	accessToken, err := token.SignedString(key.PrivateKey)
+	if err != nil {
+		t.Fatalf("failed to sign access token: %v", err)
+	}
+	return accessToken
+}
+
+func newTestAccessTokenValidator(server *testJwksServer) AccessTokenValidator {
+	return AccessTokenValidator{
+		Issuer:   testAccessTokenIssuer,
+		Audience: testAccessTokenAudience,
+		Keys:     NewJwksCache(server.URL, jwksMinRefreshInterval),
+	}
+}
+
+func TestAccessTokenValidatorAcceptsValidToken(t *testing.T) {
+	key := newTestSigningKey(t)
+	server := newTestJwksServer(t, key)
+	validator := newTestAccessTokenValidator(server)
+
+	introspection, err, rejected := validator.Validate(context.Background(), newTestAccessToken(t, key, nil), time.Now())
+	if err != nil || rejected {
+		t.Fatalf("Validate() = %v, rejected %v, want nil", err, rejected)
+	}
+	if introspection.Subject != "alice" || introspection.Client() != "client" || !introspection.Active {
+		t.Errorf("Validate() = %+v, want active token of subject 'alice' and client 'client'", introspection)
+	}
+}
+
+func TestAccessTokenValidatorRejectsInvalidClaims(t *testing.T) {
+	key := newTestSigningKey(t)
+	server := newTestJwksServer(t, key)
+	validator := newTestAccessTokenValidator(server)
+
+	tests := []struct {
+		name   string
+		claims jwt.MapClaims
+	}{
+		{"wrong issuer", jwt.MapClaims{"iss": "https://attacker.example.com"}},
+		{"wrong audience", jwt.MapClaims{"aud": "other"}},
+		{"typ mismatch", jwt.MapClaims{"typ": "ID"}},
+		{"expired", jwt.MapClaims{"exp": time.Now().Add(-time.Minute).Unix()}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err, rejected := validator.Validate(context.Background(), newTestAccessToken(t, key, test.claims), time.Now())
+			if err == nil || !rejected {
+				t.Errorf("Validate() = %v, rejected %v, want rejection", err, rejected)
+			}
+		})
+	}
+}
+
+func TestAccessTokenValidatorRefreshesJwksForUnknownKeyId(t *testing.T) {
+	key := newTestSigningKey(t)
+	server := newTestJwksServer(t, key)
+	validator := newTestAccessTokenValidator(server)
+	ctx := context.Background()
+
+	if _, err, _ := validator.Validate(ctx, newTestAccessToken(t, key, nil), time.Now()); err != nil {
+		t.Fatalf("Validate() = %v, want nil", err)
+	}
+	if requests := server.requests.Load(); requests != 1 {
+		t.Fatalf("JWK set requested %d times, want 1", requests)
+	}
+
+	// A token of a key which is not published is rejected without refreshing within the minimum refresh interval
+	rotatedKey := newTestSigningKey(t)
+	server.AddKey(rotatedKey)
+	_, err, rejected := validator.Validate(ctx, newTestAccessToken(t, rotatedKey, nil), time.Now())
+	if err == nil || !rejected {
+		t.Fatalf("Validate() within refresh interval = %v, rejected %v, want rejection", err, rejected)
+	}
+	if requests := server.requests.Load(); requests != 1 {
+		t.Fatalf("JWK set requested %d times within refresh interval, want 1", requests)
+	}
+
+	// After the minimum refresh interval, the unknown key ID triggers a refresh
+	validator.Keys.mutex.Lock()
+	validator.Keys.refreshedAt = time.Now().Add(-jwksMinRefreshInterval)
+	validator.Keys.mutex.Unlock()
+	if _, err, _ := validator.Validate(ctx, newTestAccessToken(t, rotatedKey, nil), time.Now()); err != nil {
+		t.Fatalf("Validate() after refresh interval = %v, want nil", err)
+	}
+	if requests := server.requests.Load(); requests != 2 {
+		t.Fatalf("JWK set requested %d times after refresh interval, want 2", requests)
+	}
+
+	// Keys of the cached JWK set are resolved without requests
+	if _, err, _ := validator.Validate(ctx, newTestAccessToken(t, key, nil), time.Now()); err != nil {
+		t.Fatalf("Validate() with cached key = %v, want nil", err)
+	}
+	if requests := server.requests.Load(); requests != 2 {
+		t.Errorf("JWK set requested %d times, want 2", requests)
+	}
+}
+
+func TestJwksCacheRefreshDoesNotBlockKnownKeys(t *testing.T) {
+	key := newTestSigningKey(t)
+	release := make(chan struct{})
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Only the first request responds immediately
+		if requests.Add(1) > 1 {
+			<-release
+		}
+		jwks, _ := JwkSetFromSigningKeys([]SigningKey{key})
+		json.NewEncoder(w).Encode(jwks)
+	}))
+	t.Cleanup(func() {
+		close(release)
+		server.Close()
+	})
+	useHttpClient(t, &http.Client{Timeout: time.Minute})
+	cache := NewJwksCache(server.URL, 0)
+	ctx := context.Background()
+	if _, err := cache.PublicKey(ctx, key.KeyId, key.Algorithm); err != nil {
+		t.Fatalf("PublicKey() = %v, want nil", err)
+	}
+
+	// An unknown key ID starts a refresh, which hangs until released
+	refreshed := make(chan error, 1)
+	go func() {
+		_, err := cache.PublicKey(ctx, "unknown", key.Algorithm)
+		refreshed <- err
+	}()
+	for requests.Load() < 2 {
+		time.Sleep(time.Millisecond)
+	}
+
+	// Known keys are resolved while the refresh is running
+	start := time.Now()
+	if _, err := cache.PublicKey(ctx, key.KeyId, key.Algorithm); err != nil {
+		t.Fatalf("PublicKey() during refresh = %v, want nil", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("PublicKey() during refresh returned after %v, want without waiting for the refresh", elapsed)
+	}
+
+	// Callers whose request ends stop waiting for the refresh
+	cancelled, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	if _, err := cache.PublicKey(cancelled, "unknown", key.Algorithm); err == nil {
+		t.Errorf("PublicKey() with expired context = nil, want error")
+	}
+	select {
+	case err := <-refreshed:
+		t.Fatalf("refresh returned %v before the JWK set was served", err)
+	default:
+	}
+}
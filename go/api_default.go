@@ -8,22 +8,26 @@
 package ict
 
 import (
-	"bytes"
+	"context"
+	"crypto"
 	"crypto/ecdsa"
 	"crypto/ed25519"
 	"crypto/elliptic"
 	"crypto/rand"
 	"crypto/rsa"
+	"crypto/x509"
 	"database/sql"
 	"encoding/base64"
 	"encoding/binary"
 	"encoding/json"
+	"encoding/pem"
 	"errors"
 	"fmt"
 	"io"
-	"log"
+	"log/slog"
 	"math/big"
 	"net/http"
+	"net/url"
 	"os"
 	"path/filepath"
 	"reflect"
@@ -31,48 +35,221 @@ import (
 	"strings"
 	"time"
 
+	"ict/verify"
+
 	"github.com/golang-jwt/jwt/v4"
 	_ "github.com/mattn/go-sqlite3"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 var appConfig AppConfiguration
-var appPrivateKey *rsa.PrivateKey
 var appDb *sql.DB
+var appShutdown = make(chan struct{})
+
+// Limits of the SQLite connection pool.
+const (
+	dbMaxOpenConnections = 4
+	dbMaxIdleConnections = 4
+	dbMaxIdleTime        = 5 * time.Minute
+)
 
-func Initialize() {
+// Initialize loads the configuration from configFile, if not empty, and the environment variables.
+func Initialize(configFile string) {
 	// Load configuration
-	config, err := LoadAppConfigurationFromEnv()
+	config, err := LoadAppConfiguration(configFile)
 	if err != nil {
-		log.Fatal("failed to load configuration: " + err.Error())
+		logFatal("failed to load configuration", err)
 	}
 	appConfig = config
 
+	// Load logger
+	logger, err := NewLogger(os.Stderr, appConfig)
+	if err != nil {
+		logFatal("failed to load logger", err)
+	}
+	slog.SetDefault(logger)
+
 	// Load private key
-	privateKey, err := ReadRsaPrivateKey(appConfig.KeyFilePath)
+	privateKey, err := ReadPrivateKey(appConfig.KeyFilePath, appConfig.SigningAlgorithm)
 	if err != nil {
-		log.Fatal("failed to load private key file: " + err.Error())
+		logFatal("failed to load private key file", err)
 	}
-	appPrivateKey = privateKey
 
 	// Load database
-	dbFile := os.Getenv("DB_SQLITE_FILE")
-	if dbFile == "" {
-		dbFile = "./db.sqlite"
-	}
-	db, err := loadDatabase(dbFile)
+	db, err := loadDatabase(appConfig.DatabaseFile)
 	if err != nil {
-		log.Fatal("Failed to load database: " + err.Error())
+		logFatal("failed to load database", err)
 	}
 	appDb = db
+
+	// Load nonce store
+	switch appConfig.NonceStore {
+	case "sqlite":
+		appNonceStore = NewSqliteNonceStore(appDb)
+		if appConfig.NonceSweepInterval > 0 {
+			appNonceSweeper = NewNonceSweeper(appDb, time.Duration(appConfig.NonceSweepInterval)*time.Second, int(appConfig.NonceSweepBatchSize))
+			appNonceSweeper.Start()
+		}
+	case "memory":
+		appNonceStore = NewMemoryNonceStore(1024)
+	case "redis":
+		nonceStore, err := NewRedisNonceStore(appConfig.RedisUrl)
+		if err != nil {
+			logFatal("failed to load nonce store", err)
+		}
+		appNonceStore = nonceStore
+	}
+
+	// Load revocation store, which is shared by all instances if nonces are
+	if appConfig.NonceStore == "redis" {
+		revocationStore, err := NewRedisRevocationStore(appConfig.RedisUrl)
+		if err != nil {
+			logFatal("failed to load revocation store", err)
+		}
+		appRevocationStore = revocationStore
+	} else {
+		appRevocationStore = NewSqliteRevocationStore(appDb)
+	}
+
+	// Load audit sink
+	auditSink, err := NewAuditSink(appConfig, appDb)
+	if err != nil {
+		logFatal("failed to load audit sink", err)
+	}
+	appAuditSink = auditSink
+
+	// Load HTTP client
+	httpClient, err := NewHttpClient(appConfig)
+	if err != nil {
+		logFatal("failed to load HTTP client", err)
+	}
+	appHttpClient = httpClient
+	appUpstreamPolicy.Retries = int(appConfig.OpRetries)
+	appUpstreamPolicy.BreakerFailureThreshold = int(appConfig.OpBreakerThreshold)
+	appUpstreamPolicy.BreakerOpenPeriod = time.Duration(appConfig.OpBreakerOpenPeriod) * time.Second
+
+	// Load TLS configuration
+	tlsConfig, err := NewTlsConfig(appConfig)
+	if err != nil {
+		logFatal("failed to load TLS configuration", err)
+	}
+	appTlsConfig = tlsConfig
+
+	// Load tracing
+	tracerProvider, err := NewTracerProvider(appConfig)
+	if err != nil {
+		logFatal("failed to load tracing", err)
+	}
+	appTracerProvider = tracerProvider
+	InitTracing(appTracerProvider)
+
+	// Load access token validator
+	if appConfig.AccessTokenValidation == "jwt" {
+		appAccessTokenValidator = &AccessTokenValidator{
+			Issuer:   appConfig.AccessTokenIssuer,
+			Audience: appConfig.AccessTokenAudience,
+			Keys:     NewJwksCache(appConfig.JwksUri, jwksMinRefreshInterval),
+		}
+	}
+
+	// Load token cache
+	if appConfig.TokenCacheTtl > 0 {
+		appTokenCache = NewTokenCache(time.Duration(appConfig.TokenCacheTtl)*time.Second, int(appConfig.TokenCacheSize))
+	}
+
+	// Load signing keys
+	signingKey := SigningKey{
+		KeyId:      appConfig.KeyId,
+		Algorithm:  appConfig.SigningAlgorithm,
+		PrivateKey: privateKey,
+	}
+	if appConfig.KeyRotationPeriod == 0 {
+		keyRing, err := NewStaticKeyRing(signingKey)
+		if err != nil {
+			logFatal("failed to load signing key", err)
+		}
+		appKeyRing = keyRing
+	} else {
+		rotationPeriod := time.Duration(appConfig.KeyRotationPeriod) * time.Second
+		retirementPeriod := time.Duration(appConfig.MaxTokenPeriod) * time.Second
+		kek, err := ReadKeyEncryptionKey(appConfig.KeyEncryptionKeyFile)
+		if err != nil {
+			logFatal("failed to load key encryption key", err)
+		}
+		keyRing, err := LoadKeyRing(appDb, kek, signingKey, appConfig.SigningAlgorithm, rotationPeriod, retirementPeriod, time.Now())
+		if err != nil {
+			logFatal("failed to load signing keys", err)
+		}
+		appKeyRing = keyRing
+		go appKeyRing.RunRotation(time.Minute, appShutdown)
+	}
+
+	// Load readiness checks
+	appReadinessChecks = NewReadinessChecks(appConfig)
+}
+
+// GetAppConfiguration returns the loaded configuration.
+func GetAppConfiguration() AppConfiguration {
+	return appConfig
+}
+
+// Shutdown stops all background workers and closes the nonce store, the revocation store, the audit sink and the database.
+func Shutdown() {
+	close(appShutdown)
+	if appNonceSweeper != nil {
+		appNonceSweeper.Stop()
+	}
+	if closer, ok := appNonceStore.(io.Closer); ok {
+		if err := closer.Close(); err != nil {
+			slog.Error("failed to close nonce store", "error", err)
+		}
+	}
+	if appRevocationStore != nil {
+		if err := appRevocationStore.Close(); err != nil {
+			slog.Error("failed to close revocation store", "error", err)
+		}
+	}
+	if appAuditSink != nil {
+		if err := appAuditSink.Close(); err != nil {
+			slog.Error("failed to close audit sink", "error", err)
+		}
+	}
+	if appDb != nil {
+		if err := appDb.Close(); err != nil {
+			slog.Error("failed to close database", "error", err)
+		}
+	}
+	if appTracerProvider != nil {
+		// Export the remaining spans
+		ctx, cancel := context.WithTimeout(context.Background(), GetShutdownTimeout())
+		defer cancel()
+		if err := appTracerProvider.Shutdown(ctx); err != nil {
+			slog.Error("failed to shut down tracing", "error", err)
+		}
+	}
+}
+
+// openDatabase opens an existing database file without preparing it, e.g., for commands which inspect the database of a running server.
+func openDatabase(dbFile string) (*sql.DB, error) {
+	if _, err := os.Stat(dbFile); err != nil {
+		return nil, errors.New("failed to open database: " + err.Error())
+	}
+	db, err := sql.Open("sqlite3", dbFile+"?_busy_timeout=5000")
+	if err != nil {
+		return nil, errors.New("failed to open database: " + err.Error())
+	}
+	db.SetMaxOpenConns(dbMaxOpenConnections)
+	return db, nil
 }
 
 func loadDatabase(dbFile string) (*sql.DB, error) {
 	// Create new database file if not exists.
 	if _, err := os.Stat(dbFile); os.IsNotExist(err) {
-		log.Print("Database file '" + dbFile + "' not found, creating new ...")
+		slog.Info("database file not found, creating new", "file", dbFile)
 		dbDirectory := filepath.Dir(dbFile)
 		if _, err = os.Stat(dbDirectory); os.IsNotExist(err) {
-			log.Print("Database file directory '" + dbDirectory + "' not found, creating new ...")
+			slog.Info("database file directory not found, creating new", "directory", dbDirectory)
 			err := os.Mkdir(dbDirectory, 0700)
 			if err != nil {
 				return nil, errors.New("Failed to create new database file directory: " + err.Error())
@@ -83,22 +260,68 @@ func loadDatabase(dbFile string) (*sql.DB, error) {
 			return nil, errors.New("Failed to create new database file: " + err.Error())
 		}
 		file.Close()
-		log.Print("Database file '" + dbFile + "' created")
+		slog.Info("database file created", "file", dbFile)
 	}
 
 	// Open database file.
-	log.Print("Open database file '" + dbFile + "' ...")
-	db, err := sql.Open("sqlite3", dbFile)
+	slog.Info("opening database file", "file", dbFile)
+	db, err := sql.Open("sqlite3", dbFile+"?_journal_mode=WAL&_busy_timeout=5000&_synchronous=NORMAL")
 	if err != nil {
 		return nil, errors.New("Failed to open database: " + err.Error())
 	}
+	db.SetMaxOpenConns(dbMaxOpenConnections)
+	db.SetMaxIdleConns(dbMaxIdleConnections)
+	db.SetConnMaxIdleTime(dbMaxIdleTime)
 
 	// Create nonces table.
-	log.Print("Preparing database ...")
+	slog.Info("preparing database")
 	_, err = db.Exec("CREATE TABLE IF NOT EXISTS nonces (nonce TEXT NOT NULL PRIMARY KEY, expires datetime);")
 	if err != nil {
 		return nil, errors.New("Failed to prepare database: Failed to create table 'nonces': " + err.Error())
 	}
+	_, err = db.Exec("CREATE INDEX IF NOT EXISTS nonces_expires ON nonces (expires);")
+	if err != nil {
+		return nil, errors.New("Failed to prepare database: Failed to create index 'nonces_expires': " + err.Error())
+	}
+
+	// Create signing keys table.
+	_, err = db.Exec("CREATE TABLE IF NOT EXISTS signing_keys (kid TEXT NOT NULL PRIMARY KEY, alg TEXT NOT NULL, state TEXT NOT NULL, private_key TEXT NOT NULL, published datetime, activated datetime, not_after datetime);")
+	if err != nil {
+		return nil, errors.New("Failed to prepare database: Failed to create table 'signing_keys': " + err.Error())
+	}
+	if err := addColumnIfMissing(db, "signing_keys", "published", "datetime"); err != nil {
+		return nil, errors.New("Failed to prepare database: " + err.Error())
+	}
+
+	// Create audit records table.
+	_, err = db.Exec("CREATE TABLE IF NOT EXISTS audit_records (seq INTEGER NOT NULL PRIMARY KEY, jti TEXT NOT NULL, sub TEXT NOT NULL, record TEXT NOT NULL, hash TEXT NOT NULL, prev_hash TEXT NOT NULL);")
+	if err != nil {
+		return nil, errors.New("Failed to prepare database: Failed to create table 'audit_records': " + err.Error())
+	}
+	_, err = db.Exec("CREATE INDEX IF NOT EXISTS audit_records_sub ON audit_records (sub);")
+	if err != nil {
+		return nil, errors.New("Failed to prepare database: Failed to create index 'audit_records_sub': " + err.Error())
+	}
+	_, err = db.Exec("CREATE INDEX IF NOT EXISTS audit_records_jti ON audit_records (jti);")
+	if err != nil {
+		return nil, errors.New("Failed to prepare database: Failed to create index 'audit_records_jti': " + err.Error())
+	}
+
+	// Create revocations table.
+	_, err = db.Exec("CREATE TABLE IF NOT EXISTS revocations (type TEXT NOT NULL, value TEXT NOT NULL, revoked_at datetime NOT NULL, reason TEXT, PRIMARY KEY (type, value));")
+	if err != nil {
+		return nil, errors.New("Failed to prepare database: Failed to create table 'revocations': " + err.Error())
+	}
+
+	// Create status list table, whose rows reserve indices without jti until the token is issued.
+	_, err = db.Exec("CREATE TABLE IF NOT EXISTS status_list (idx INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT, jti TEXT, sub TEXT, jkt TEXT, iat datetime, exp datetime);")
+	if err != nil {
+		return nil, errors.New("Failed to prepare database: Failed to create table 'status_list': " + err.Error())
+	}
+	_, err = db.Exec("CREATE INDEX IF NOT EXISTS status_list_exp ON status_list (exp);")
+	if err != nil {
+		return nil, errors.New("Failed to prepare database: Failed to create index 'status_list_exp': " + err.Error())
+	}
 
 	// Clear old values from nonces table.
 	_, err = db.Exec("DELETE FROM nonces WHERE expires <= datetime('now');")
@@ -106,9 +329,43 @@ func loadDatabase(dbFile string) (*sql.DB, error) {
 		return nil, errors.New("Failed to prepare database: Failed to delete old nonces: " + err.Error())
 	}
 
+	// Clear expired tokens and reservations of tokens which were never issued from status list table.
+	// Their indices are not reused, since the table is AUTOINCREMENT.
+	_, err = db.Exec("DELETE FROM status_list WHERE exp IS NULL OR exp <= datetime('now');")
+	if err != nil {
+		return nil, errors.New("Failed to prepare database: Failed to delete expired status list entries: " + err.Error())
+	}
+
 	return db, nil
 }
 
+// addColumnIfMissing adds a column to a table which was created by a previous version.
+func addColumnIfMissing(db *sql.DB, table string, column string, columnType string) error {
+	rows, err := db.Query("SELECT name FROM pragma_table_info(?)", table)
+	if err != nil {
+		return errors.New("Failed to read columns of table '" + table + "': " + err.Error())
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return errors.New("Failed to read columns of table '" + table + "': " + err.Error())
+		}
+		if name == column {
+			return nil
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return errors.New("Failed to read columns of table '" + table + "': " + err.Error())
+	}
+	rows.Close()
+
+	if _, err := db.Exec("ALTER TABLE " + table + " ADD COLUMN " + column + " " + columnType); err != nil {
+		return errors.New("Failed to add column '" + column + "' to table '" + table + "': " + err.Error())
+	}
+	return nil
+}
+
 func Base64ToBigInt(s string) (*big.Int, error) {
 	// Parse base64url encoded string to bytes
 	data, err := base64.RawURLEncoding.DecodeString(s)
@@ -155,6 +412,108 @@ func ReadEcPrivateKey(fileName string) (*ecdsa.PrivateKey, error) {
 	return privateKey, nil
 }
 
+// ReadPrivateKey reads a PEM encoded RSA, EC or Ed25519 private key from fileName
+// and ensures that it can be used to sign tokens with the signing algorithm alg.
+func ReadPrivateKey(fileName string, alg jwt.SigningMethod) (crypto.Signer, error) {
+	privateData, err := os.ReadFile(fileName)
+	if err != nil {
+		return nil, errors.New("Failed to read private key file: " + err.Error())
+	}
+	privateKey, err := PrivateKeyFromPem(privateData)
+	if err != nil {
+		return nil, errors.New("Failed to parse private key: " + err.Error())
+	}
+	err = ValidatePrivateKeyAlgorithm(privateKey, alg)
+	if err != nil {
+		return nil, errors.New("Private key does not match signing algorithm: " + err.Error())
+	}
+	return privateKey, nil
+}
+
+// PrivateKeyFromPem parses the first private key found in PEM encoded data.
+// Supported are PKCS#1 RSA keys, SEC 1 EC keys and PKCS#8 RSA, EC or Ed25519 keys.
+func PrivateKeyFromPem(data []byte) (crypto.Signer, error) {
+	for {
+		// Decode next PEM block
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			return nil, errors.New("no private key found in PEM data")
+		}
+
+		// Parse private key depending on PEM type
+		switch block.Type {
+		case "RSA PRIVATE KEY":
+			privateKey, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+			if err != nil {
+				return nil, errors.New("failed to parse PKCS#1 RSA private key: " + err.Error())
+			}
+			return privateKey, nil
+		case "EC PRIVATE KEY":
+			privateKey, err := x509.ParseECPrivateKey(block.Bytes)
+			if err != nil {
+				return nil, errors.New("failed to parse SEC 1 EC private key: " + err.Error())
+			}
+			return privateKey, nil
+		case "PRIVATE KEY":
+			privateKey, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+			if err != nil {
+				return nil, errors.New("failed to parse PKCS#8 private key: " + err.Error())
+			}
+			switch privateKey := privateKey.(type) {
+			case *rsa.PrivateKey:
+				return privateKey, nil
+			case *ecdsa.PrivateKey:
+				return privateKey, nil
+			case ed25519.PrivateKey:
+				return privateKey, nil
+			default:
+				return nil, errors.New("PKCS#8 private key type is not supported")
+			}
+		case "ENCRYPTED PRIVATE KEY":
+			return nil, errors.New("encrypted private keys are not supported")
+		default:
+			// Skip other blocks, e.g., "EC PARAMETERS" written by 'openssl ecparam'
+			continue
+		}
+	}
+}
+
+// ValidatePrivateKeyAlgorithm ensures that privateKey can be used to sign tokens with signing algorithm alg.
+func ValidatePrivateKeyAlgorithm(privateKey crypto.Signer, alg jwt.SigningMethod) error {
+	switch privateKey := privateKey.(type) {
+	case *rsa.PrivateKey:
+		switch alg {
+		case jwt.SigningMethodRS256, jwt.SigningMethodRS384, jwt.SigningMethodRS512:
+			return nil
+		}
+		return errors.New("RSA private key cannot be used with signing algorithm '" + alg.Alg() + "'")
+	case *ecdsa.PrivateKey:
+		var expectedCurve string
+		switch alg {
+		case jwt.SigningMethodES256:
+			expectedCurve = "P-256"
+		case jwt.SigningMethodES384:
+			expectedCurve = "P-384"
+		case jwt.SigningMethodES512:
+			expectedCurve = "P-521"
+		default:
+			return errors.New("EC private key cannot be used with signing algorithm '" + alg.Alg() + "'")
+		}
+		if curve := privateKey.Curve.Params().Name; curve != expectedCurve {
+			return errors.New("EC private key on curve '" + curve + "' cannot be used with signing algorithm '" + alg.Alg() + "', expected curve '" + expectedCurve + "'")
+		}
+		return nil
+	case ed25519.PrivateKey:
+		if alg != jwt.SigningMethodEdDSA {
+			return errors.New("Ed25519 private key cannot be used with signing algorithm '" + alg.Alg() + "'")
+		}
+		return nil
+	default:
+		return errors.New("private key type is not supported")
+	}
+}
+
 func ReadEcPublicKey(fileName string) (*ecdsa.PublicKey, error) {
 	publicData, err := os.ReadFile(fileName)
 	if err != nil {
@@ -238,9 +597,13 @@ func BearerTokenFromAuthorizationHeader(r *http.Request) (string, error) {
 	return authorizationHeaderParts[1], nil
 }
 
-func LogAndSendError(w http.ResponseWriter, statusCode int, status string, description string, details string) {
-	// Log error
-	log.Print("[ERROR] " + details)
+func LogAndSendError(w http.ResponseWriter, r *http.Request, statusCode int, status string, description string, details string) {
+	// Log error, client errors are expected in operation
+	level := slog.LevelWarn
+	if statusCode >= 500 {
+		level = slog.LevelError
+	}
+	slog.Log(r.Context(), level, "request failed", "status", statusCode, "error", details)
 
 	w.WriteHeader(statusCode)
 	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
@@ -251,36 +614,40 @@ func LogAndSendError(w http.ResponseWriter, statusCode int, status string, descr
 	})
 }
 
-func RequestUserinfo(bearerToken string, uri string, issuer string) (map[string]interface{}, error, bool) {
-	// Create new http client
-	client := &http.Client{}
-
-	// Create new http request
-	req, err := http.NewRequest("GET", uri, nil)
-	if err != nil {
-		return nil, errors.New("failed to create userinfo request to '" + uri + "': " + err.Error()), false
-	}
-	if appConfig.UserinfoHost != "" {
-		req.Host = appConfig.UserinfoHost
-	}
-	req.Header.Set("Authorization", "Bearer "+bearerToken)
-	// Set Host header to prevent that introspection endpoint responds with {"active":false}.
-	// See: https://stackoverflow.com/questions/53721588/keycloak-token-introspection-always-fails-with-activefalse
-	// issuerParts := strings.Split(issuer, "/")
-	// hostname := issuerParts[2]
-	// req.Host = hostname
+func RequestUserinfo(ctx context.Context, bearerToken string, uri string, issuer string) (claims map[string]interface{}, err error, authFailed bool) {
+	start := time.Now()
+	defer func() {
+		// A rejected access token is a valid response of the OpenID Provider
+		observeUpstreamRequest(upstreamUserinfo, start, err != nil && !authFailed)
+	}()
 
 	// Send http request and validate response
-	res, err := client.Do(req)
+	res, err := DoUpstreamRequest(ctx, uri, func(ctx context.Context) (*http.Request, error) {
+		// Create new http request
+		req, err := http.NewRequestWithContext(ctx, "GET", uri, nil)
+		if err != nil {
+			return nil, errors.New("failed to create userinfo request to '" + uri + "': " + err.Error())
+		}
+		if appConfig.UserinfoHost != "" {
+			req.Host = appConfig.UserinfoHost
+		}
+		req.Header.Set("Authorization", "Bearer "+bearerToken)
+		// Set Host header to prevent that introspection endpoint responds with {"active":false}.
+		// See: https://stackoverflow.com/questions/53721588/keycloak-token-introspection-always-fails-with-activefalse
+		// issuerParts := strings.Split(issuer, "/")
+		// hostname := issuerParts[2]
+		// req.Host = hostname
+		return req, nil
+	})
 	if err != nil {
-		return nil, errors.New("failed to send userinfo request to '" + uri + "': " + err.Error()), false
+		return nil, err, false
 	}
+	defer res.Body.Close()
 	if res.StatusCode != 200 {
 		return nil, errors.New("failed to get userinfo response from '" + uri + "'. status code: " + fmt.Sprint(res.StatusCode) + ", status: '" + res.Status + "'"), res.StatusCode == 401
 	}
 
 	// Parse response
-	var claims map[string]interface{}
 	err = json.NewDecoder(res.Body).Decode(&claims)
 	if err != nil {
 		return nil, errors.New("failed to parse userinfo response: " + err.Error()), false
@@ -331,102 +698,40 @@ func EllipticCurveFromJson(json map[string]interface{}, attributeName string) (e
 	return curve, nil
 }
 
+// EcdsaPublicKeyFromJson parses an elliptic curve public key JWK and returns the key and its required members.
 func EcdsaPublicKeyFromJson(jwk map[string]interface{}) (*ecdsa.PublicKey, map[string]interface{}, error) {
-	publicKey := new(ecdsa.PublicKey)
-	publicKeyJwk := make(map[string]interface{})
-	publicKeyJwk["kty"] = "EC"
-
-	// Parse curve name
-	crv, err := EllipticCurveFromJson(jwk, "crv")
-	if err != nil {
-		return nil, nil, errors.New("failed to read curve name: " + err.Error())
-	}
-	publicKey.Curve = crv
-	crvString, err := StringFromJson(jwk, "crv")
-	if err != nil {
-		return nil, nil, errors.New("curve name not found: " + err.Error())
-	}
-	publicKeyJwk["crv"] = crvString
-
-	// Parse x value
-	x, err := BigIntFromJsonBase64(jwk, "x")
-	if err != nil {
-		return nil, nil, errors.New("failed to read x value: " + err.Error())
-	}
-	publicKey.X = x
-	xString, err := StringFromJson(jwk, "x")
-	if err != nil {
-		return nil, nil, errors.New("x value not found: " + err.Error())
-	}
-	publicKeyJwk["x"] = xString
-
-	// Parse y value
-	y, err := BigIntFromJsonBase64(jwk, "y")
-	if err != nil {
-		return nil, nil, errors.New("failed to read y value: " + err.Error())
-	}
-	publicKey.Y = y
-	yString, err := StringFromJson(jwk, "y")
+	publicKey, err := verify.EcdsaPublicKeyFromJwk(jwk)
 	if err != nil {
-		return nil, nil, errors.New("y value not found: " + err.Error())
+		return nil, nil, err
 	}
-	publicKeyJwk["y"] = yString
-
-	// Return public key
-	return publicKey, publicKeyJwk, nil
+	return publicKey, requiredJwkMembers(jwk, "kty", "crv", "x", "y"), nil
 }
-func RsaPublicKeyFromJson(jwk map[string]interface{}) (*rsa.PublicKey, map[string]interface{}, error) {
-	publicKey := new(rsa.PublicKey)
-	publicKeyJwk := make(map[string]interface{})
-	publicKeyJwk["kty"] = "RSA"
 
-	// Parse x value
-	e, err := IntFromJsonBase64(jwk, "e")
-	if err != nil {
-		return nil, nil, errors.New("failed to read exponent: " + err.Error())
-	}
-	publicKey.E = e
-	eString, err := StringFromJson(jwk, "e")
+// RsaPublicKeyFromJson parses an RSA public key JWK and returns the key and its required members.
+func RsaPublicKeyFromJson(jwk map[string]interface{}) (*rsa.PublicKey, map[string]interface{}, error) {
+	publicKey, err := verify.RsaPublicKeyFromJwk(jwk)
 	if err != nil {
-		return nil, nil, errors.New("exponent not found: " + err.Error())
+		return nil, nil, err
 	}
-	publicKeyJwk["e"] = eString
+	return publicKey, requiredJwkMembers(jwk, "kty", "e", "n"), nil
+}
 
-	// Parse modulus value
-	n, err := BigIntFromJsonBase64(jwk, "n")
-	if err != nil {
-		return nil, nil, errors.New("failed to read modulus: " + err.Error())
-	}
-	publicKey.N = n
-	nString, err := StringFromJson(jwk, "n")
+// EdDsaPublicKeyFromJson parses an Ed25519 public key JWK and returns the key and its required members.
+func EdDsaPublicKeyFromJson(jwk map[string]interface{}) (*ed25519.PublicKey, map[string]interface{}, error) {
+	publicKey, err := verify.EdDsaPublicKeyFromJwk(jwk)
 	if err != nil {
-		return nil, nil, errors.New("modulus not found: " + err.Error())
+		return nil, nil, err
 	}
-	publicKeyJwk["n"] = nString
-
-	// Return public key
-	return publicKey, publicKeyJwk, nil
+	return &publicKey, requiredJwkMembers(jwk, "kty", "crv", "x"), nil
 }
-func EdDsaPublicKeyFromJson(jwk map[string]interface{}) (*ed25519.PublicKey, map[string]interface{}, error) {
-	publicKeyJwk := make(map[string]interface{})
-	publicKeyJwk["kty"] = "OKP"
-	publicKeyJwk["crv"] = "Ed25519"
 
-	// Parse x value
-	x, err := ByteArrayFromJsonBase64(jwk, "x")
-	if err != nil {
-		return nil, nil, errors.New("failed to read x value: " + err.Error())
+// requiredJwkMembers copies the members of a JWK which identify its public key, e.g., to embed it in a confirmation claim.
+func requiredJwkMembers(jwk map[string]interface{}, members ...string) map[string]interface{} {
+	publicKeyJwk := make(map[string]interface{}, len(members))
+	for _, member := range members {
+		publicKeyJwk[member] = jwk[member]
 	}
-	var publicKey ed25519.PublicKey
-	publicKey = x
-	xString, err := StringFromJson(jwk, "x")
-	if err != nil {
-		return nil, nil, errors.New("x value not found: " + err.Error())
-	}
-	publicKeyJwk["x"] = xString
-
-	// Return public key
-	return &publicKey, publicKeyJwk, nil
+	return publicKeyJwk
 }
 
 func PublicKeyFromJwt(token *jwt.Token) (interface{}, map[string]interface{}, error) {
@@ -437,7 +742,11 @@ func PublicKeyFromJwt(token *jwt.Token) (interface{}, map[string]interface{}, er
 	}
 
 	// Extract public key
-	switch token.Method {
+	return PublicKeyFromJwk(jwk, token.Method)
+}
+
+func PublicKeyFromJwk(jwk map[string]interface{}, alg jwt.SigningMethod) (interface{}, map[string]interface{}, error) {
+	switch alg {
 	// Elliptic Curve:
 	case jwt.SigningMethodES256:
 		fallthrough
@@ -470,7 +779,54 @@ func PublicKeyFromJwt(token *jwt.Token) (interface{}, map[string]interface{}, er
 		return *publicKey, publicKeyJwk, nil
 	// Not supported:
 	default:
-		return nil, nil, errors.New("signing algorithm '" + token.Method.Alg() + "' not supported")
+		return nil, nil, errors.New("signing algorithm '" + alg.Alg() + "' not supported")
+	}
+}
+
+// Reasons of proof of possession validation failures.
+const (
+	PopFailureMalformed       = "malformed"
+	PopFailureBadSignature    = "bad_signature"
+	PopFailureExpired         = "expired"
+	PopFailureInvalidClaims   = "invalid_claims"
+	PopFailureSubjectMismatch = "subject_mismatch"
+	PopFailureReplay          = "replay"
+)
+
+// PopValidationError is returned if a proof of possession token is rejected.
+type PopValidationError struct {
+	// Reason of the rejection, one of the PopFailure constants.
+	Reason string
+	Err    error
+}
+
+func (err *PopValidationError) Error() string {
+	return err.Err.Error()
+}
+
+func (err *PopValidationError) Unwrap() error {
+	return err.Err
+}
+
+func popValidationError(reason string, message string) error {
+	return &PopValidationError{Reason: reason, Err: errors.New(message)}
+}
+
+// popParseFailureReason classifies an error of parsing and verifying a proof of possession token.
+func popParseFailureReason(err error) string {
+	var validationErr *jwt.ValidationError
+	if !errors.As(err, &validationErr) {
+		return PopFailureMalformed
+	}
+	switch {
+	case validationErr.Errors&jwt.ValidationErrorSignatureInvalid != 0:
+		return PopFailureBadSignature
+	case validationErr.Errors&(jwt.ValidationErrorExpired|jwt.ValidationErrorNotValidYet|jwt.ValidationErrorIssuedAt) != 0:
+		return PopFailureExpired
+	case validationErr.Errors&jwt.ValidationErrorClaimsInvalid != 0:
+		return PopFailureInvalidClaims
+	default:
+		return PopFailureMalformed
 	}
 }
 
@@ -493,29 +849,22 @@ func ParseProofOfPossessionFromRequestBody(r *http.Request) (*jwt.Token, jwt.Map
 		return key, nil
 	})
 	if err != nil {
-		return nil, jwt.MapClaims{}, nil, errors.New("failed to parse proof of possession token: " + err.Error())
+		return nil, jwt.MapClaims{}, nil, popValidationError(popParseFailureReason(err), "failed to parse proof of possession token: "+err.Error())
 	}
 
 	return token, claims, publicKeyJwk, nil
 }
 
-func ValidateProofOfPossession(popToken *jwt.Token, popClaims jwt.MapClaims, userinfoClaims map[string]interface{}, config AppConfiguration, now time.Time) error {
+// ValidateProofOfPossessionClaims validates the claims of the proof of possession token which do not depend on the OpenID Provider.
+func ValidateProofOfPossessionClaims(popToken *jwt.Token, popClaims jwt.MapClaims, config AppConfiguration, now time.Time) error {
 	// Validate proof of possession token
 	if !popToken.Valid {
-		return errors.New("proof of possession token is not valid")
+		return popValidationError(PopFailureMalformed, "proof of possession token is not valid")
 	}
 
-	// Validate and compare subject
-	userinfoSub, err := StringFromJson(userinfoClaims, "sub")
-	if err != nil {
-		return errors.New("subject claim in userinfo response not found")
-	}
-	popSub, err := StringFromJson(popClaims, "sub")
-	if err != nil {
-		return errors.New("subject claim in proof of possession token not found")
-	}
-	if userinfoSub != popSub {
-		return errors.New("invalid subject claim in proof of possession token")
+	// Validate subject
+	if _, err := StringFromJson(popClaims, "sub"); err != nil {
+		return popValidationError(PopFailureMalformed, "subject claim in proof of possession token not found")
 	}
 
 	// Validate audience
@@ -523,49 +872,67 @@ func ValidateProofOfPossession(popToken *jwt.Token, popClaims jwt.MapClaims, use
 	if !ok {
 		aud, err := StringFromJson(popClaims, "aud")
 		if err != nil {
-			return errors.New("invalid audience claim in proof of possession token! No audience claim received!")
+			return popValidationError(PopFailureInvalidClaims, "invalid audience claim in proof of possession token! No audience claim received!")
 		}
-		return errors.New("invalid audience claim in proof of possession token! Expected \"" + config.Issuer + "\" but received \"" + aud + "\"")
+		return popValidationError(PopFailureInvalidClaims, "invalid audience claim in proof of possession token! Expected \""+config.Issuer+"\" but received \""+aud+"\"")
 	}
 
-	// Verify nonce validity
-	nonce, err := StringFromJson(popClaims, "jti")
-	if err != nil {
-		return errors.New("jti claim in proof of possession token not found")
+	// Verify expiration before persisting the nonce
+	nowUnix := now.Unix()
+	if !popClaims.VerifyExpiresAt(nowUnix, true) ||
+		!popClaims.VerifyNotBefore(nowUnix, false) ||
+		!popClaims.VerifyIssuedAt(nowUnix, true) {
+		return popValidationError(PopFailureExpired, "token expired or is not yet valid")
 	}
-	rows, err := appDb.Query("SELECT expires FROM nonces WHERE nonce = ?", nonce)
-	if err != nil {
-		return errors.New("failed to verify nonce: database error: " + err.Error())
+
+	// Validate nonce
+	if _, err := StringFromJson(popClaims, "jti"); err != nil {
+		return popValidationError(PopFailureMalformed, "jti claim in proof of possession token not found")
 	}
-	if rows.Next() {
-		var expiryDate time.Time
-		rows.Scan(&expiryDate)
-		rows.Close()
-		return errors.New("invalid proof of possession token: token already used, expires at " + expiryDate.String())
+	if _, err := Int64FromJson(popClaims, "exp"); err != nil {
+		return popValidationError(PopFailureMalformed, "expiration claim not found in proof of possession token or invalid data type: "+err.Error())
 	}
-	rows.Close()
-	expUnixInt, err := Int64FromJson(popClaims, "exp")
+
+	return nil
+}
+
+// ValidateProofOfPossession validates the proof of possession token, compares its subject with the userinfo and persists its nonce.
+func ValidateProofOfPossession(ctx context.Context, popToken *jwt.Token, popClaims jwt.MapClaims, userinfoClaims map[string]interface{}, config AppConfiguration, now time.Time) error {
+	// Validate claims
+	if err := ValidateProofOfPossessionClaims(popToken, popClaims, config, now); err != nil {
+		return err
+	}
+
+	// Validate and compare subject
+	userinfoSub, err := StringFromJson(userinfoClaims, "sub")
 	if err != nil {
-		return errors.New("expiration claim not found in proof of possession token or invalid data type: " + err.Error())
+		return errors.New("subject claim in userinfo response not found")
 	}
-	exp := time.Unix(expUnixInt, 0)
-	_, err = appDb.Query("INSERT INTO nonces (nonce, expires) VALUES (?, ?)", nonce, exp)
+	popSub, err := StringFromJson(popClaims, "sub")
 	if err != nil {
-		return errors.New("failed to insert nonce '" + nonce + "' with expiration date '" + exp.String() + ": " + err.Error())
+		return popValidationError(PopFailureMalformed, "subject claim in proof of possession token not found")
+	}
+	if userinfoSub != popSub {
+		return popValidationError(PopFailureSubjectMismatch, "invalid subject claim in proof of possession token")
 	}
 
-	// Verify expiration
-	nowUnix := now.Unix()
-	if !popClaims.VerifyExpiresAt(nowUnix, true) ||
-		!popClaims.VerifyNotBefore(nowUnix, false) ||
-		!popClaims.VerifyIssuedAt(nowUnix, true) {
-		return errors.New("token expired or is not yet valid")
+	// Verify nonce validity
+	nonce, _ := StringFromJson(popClaims, "jti")
+	expUnixInt, _ := Int64FromJson(popClaims, "exp")
+	ctx, span := tracer.Start(ctx, "StoreNonce")
+	err = appNonceStore.Add(ctx, nonce, time.Unix(expUnixInt, 0))
+	endSpan(span, err)
+	if errors.Is(err, ErrNonceReplayed) {
+		return popValidationError(PopFailureReplay, "invalid proof of possession token: token already used")
+	}
+	if err != nil {
+		return err
 	}
 
 	return nil
 }
 
-func GenerateIct(privateKey interface{}, algorithm jwt.SigningMethod, tokenClaims jwt.MapClaims, publicKeyJwk map[string]interface{}, userinfoClaims map[string]interface{}, config AppConfiguration, contexts []string, audience string, withAudience bool) (string, []string, int64, error) {
+func GenerateIct(ctx context.Context, signingKey SigningKey, tokenClaims jwt.MapClaims, publicKeyJwk map[string]interface{}, certificateThumbprint string, userinfoClaims map[string]interface{}, config AppConfiguration, contexts []string, audience string, withAudience bool, statusListIndex uint64, statusListUri string) (string, []string, jwt.MapClaims, error) {
 	// Compute token validity
 	expiresIn := config.DefaultTokenPeriod
 	if tokenLifetime, ok := tokenClaims["token_lifetime"]; ok {
@@ -575,7 +942,7 @@ func GenerateIct(privateKey interface{}, algorithm jwt.SigningMethod, tokenClaim
 			var err error
 			popTokenLifetime, err = strconv.ParseUint(tokenLifetime.(string), 10, 64)
 			if err != nil {
-				return "", nil, 0, err
+				return "", nil, nil, err
 			}
 		case float64:
 			popTokenLifetime = uint64(tokenLifetime.(float64))
@@ -584,7 +951,7 @@ func GenerateIct(privateKey interface{}, algorithm jwt.SigningMethod, tokenClaim
 		case uint64:
 			popTokenLifetime = uint64(tokenLifetime.(uint64))
 		default:
-			return "", nil, 0, errors.New("Unexpected type of 'token_lifetime'")
+			return "", nil, nil, errors.New("Unexpected type of 'token_lifetime'")
 		}
 		if popTokenLifetime > uint64(config.MaxTokenPeriod) {
 			expiresIn = uint64(config.MaxTokenPeriod)
@@ -640,7 +1007,7 @@ func GenerateIct(privateKey interface{}, algorithm jwt.SigningMethod, tokenClaim
 
 	subject, err := StringFromJson(userinfoClaims, "sub")
 	if err != nil {
-		return "", nil, 0, errors.New("subject not found")
+		return "", nil, nil, errors.New("subject not found")
 	}
 	requestedClaims["sub"] = subject
 	requestedClaims["iss"] = config.Issuer
@@ -655,6 +1022,9 @@ func GenerateIct(privateKey interface{}, algorithm jwt.SigningMethod, tokenClaim
 		requestedClaims["aud"] = audience
 	}
 
+	// Reference the token's status in the status list to check for revocation.
+	requestedClaims["status"] = StatusClaim(statusListIndex, statusListUri)
+
 	// Set time constraints
 	now := time.Now().Unix()
 	expiresAt := now + int64(expiresIn)
@@ -665,65 +1035,86 @@ func GenerateIct(privateKey interface{}, algorithm jwt.SigningMethod, tokenClaim
 	// Add confirmation header
 	confirmation := make(map[string]interface{})
 	confirmation["jwk"] = publicKeyJwk
+	if certificateThumbprint != "" {
+		confirmation["x5t#S256"] = certificateThumbprint
+	}
 	requestedClaims["cnf"] = confirmation
 
 	// Generate ICT
-	ict := jwt.NewWithClaims(algorithm, requestedClaims)
-	ict.Header["kid"] = config.KeyId
+	ict := jwt.NewWithClaims(signingKey.Algorithm, requestedClaims)
+	ict.Header["kid"] = signingKey.KeyId
 	ict.Header["typ"] = "jwt+ict"
-	iatString, err := ict.SignedString(privateKey)
+	if err := ctx.Err(); err != nil {
+		return "", nil, nil, errors.New("failed to sign Identity Certification Token: " + err.Error())
+	}
+	_, span := tracer.Start(ctx, "SignIct", trace.WithAttributes(attribute.String("ict.alg", signingKey.Algorithm.Alg())))
+	iatString, err := ict.SignedString(signingKey.PrivateKey)
+	endSpan(span, err)
 	if err != nil {
-		return "", nil, 0, errors.New("failed to sign Identity Certification Token: " + err.Error())
+		return "", nil, nil, errors.New("failed to sign Identity Certification Token: " + err.Error())
 	}
 
-	return iatString, claimNames, expiresAt, nil
+	return iatString, claimNames, requestedClaims, nil
 }
 
-func IntrospectAccessToken(accessToken string, tokenIntrospectionEndpoint string) (map[string]interface{}, error) {
+// IntrospectAccessToken requests the token introspection endpoint and ensures that the access token is active at time now.
+// The returned bool is true if the access token was rejected.
+func IntrospectAccessToken(ctx context.Context, accessToken string, tokenIntrospectionEndpoint string, now time.Time) (introspection IntrospectionResponse, err error, tokenRejected bool) {
+	start := time.Now()
+	defer func() {
+		// An inactive access token is a valid response of the OpenID Provider
+		observeUpstreamRequest(upstreamIntrospection, start, err != nil && !tokenRejected)
+	}()
+
 	// Generate HTTP POST Body for token introspection.
-	body := bytes.NewBuffer([]byte("token=" + accessToken + "&token_type_hint=access_token"))
+	form := url.Values{}
+	form.Set("token", accessToken)
+	form.Set("token_type_hint", "access_token")
+	body := form.Encode()
 
 	// Send HTTP POST request to token introspection endpoint.
-	req, err := http.NewRequest("POST", tokenIntrospectionEndpoint, body)
-	if err != nil {
-		return nil, errors.Join(errors.New("Failed to create token introspection request"), err)
-	}
-	if appConfig.TokenIntrospectionHost != "" {
-		req.Host = appConfig.TokenIntrospectionHost
-	}
-	// Add headers:
-	req.Header.Add("accept", "application/json")
-	req.Header.Add("content-type", "application/x-www-form-urlencoded")
-	req.Header.Add("authorization", appConfig.IntrospectionCredentials)
-	res, err := http.DefaultClient.Do(req)
+	res, err := DoUpstreamRequest(ctx, tokenIntrospectionEndpoint, func(ctx context.Context) (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, "POST", tokenIntrospectionEndpoint, strings.NewReader(body))
+		if err != nil {
+			return nil, errors.New("failed to create token introspection request to '" + tokenIntrospectionEndpoint + "': " + err.Error())
+		}
+		if appConfig.TokenIntrospectionHost != "" {
+			req.Host = appConfig.TokenIntrospectionHost
+		}
+		// Add headers:
+		req.Header.Add("accept", "application/json")
+		req.Header.Add("content-type", "application/x-www-form-urlencoded")
+		req.Header.Add("authorization", appConfig.IntrospectionCredentials)
+		return req, nil
+	})
 	if err != nil {
-		return nil, errors.Join(errors.New("Failed to request token introspection endpoint"), err)
+		return IntrospectionResponse{}, err, false
 	}
 	defer res.Body.Close()
+	if res.StatusCode != 200 {
+		return IntrospectionResponse{}, errors.New("failed to get token introspection response from '" + tokenIntrospectionEndpoint + "'. status code: " + fmt.Sprint(res.StatusCode) + ", status: '" + res.Status + "'"), false
+	}
 
 	// Parse token introspection response.
-	var introspectionDocument map[string]interface{}
-	err = json.NewDecoder(res.Body).Decode(&introspectionDocument)
+	err = json.NewDecoder(res.Body).Decode(&introspection)
 	if err != nil {
-		return nil, errors.Join(errors.New("Failed to parse token introspection response"), err)
+		return IntrospectionResponse{}, errors.New("failed to parse token introspection response: " + err.Error()), false
 	}
 
-	fmt.Print("introspection: ")
-	is, err := json.Marshal(introspectionDocument)
-	if err == nil {
-		fmt.Println(string(is))
-	} else {
-		fmt.Println("error")
+	// Validate introspected token
+	err = introspection.Validate(now)
+	if err != nil {
+		return IntrospectionResponse{}, errors.New("invalid access token: " + err.Error()), true
 	}
 
 	// Return parsed response
-	return introspectionDocument, nil
+	return introspection, nil, false
 }
 
-func GetContexts(accessTokenClaims map[string]interface{}) ([]string, error) {
+func GetContexts(introspection IntrospectionResponse) ([]string, error) {
 	// Get scope claim from access token.
-	scopeClaim, scopeExists := accessTokenClaims["scope"].(string)
-	if !scopeExists {
+	scopeClaim := introspection.Scope
+	if scopeClaim == "" {
 		return nil, errors.New("scope claim not found")
 	}
 
@@ -750,9 +1141,12 @@ func GetContexts(accessTokenClaims map[string]interface{}) ([]string, error) {
 	return contexts, nil
 }
 
+// GenIct issues an Identity Certification Token for the key of the Proof of Possession Token in the request body.
+// Issuance is refused if the key (jkt) is revoked, since it may be compromised. Revocations by subject (sub) only apply to
+// tokens issued before, so the subject can sign in again, and revocations by token ID (jti) only apply to issued tokens.
+// Both are checked at '/verify' and '/status'.
 func GenIct(w http.ResponseWriter, r *http.Request) {
 	origin := r.Header.Get("Origin")
-	log.Print("origin: " + origin)
 	if origin != "" {
 		w.Header().Set("Access-Control-Allow-Origin", origin)
 	}
@@ -762,71 +1156,141 @@ func GenIct(w http.ResponseWriter, r *http.Request) {
 	// Get bearer token from authorization header
 	bearerToken, err := BearerTokenFromAuthorizationHeader(r)
 	if err != nil {
-		LogAndSendError(w, http.StatusUnauthorized, "unauthorized", "bearer authentication required", "failed to read bearer token: "+err.Error())
+		LogAndSendError(w, r, http.StatusUnauthorized, "unauthorized", "bearer authentication required", "failed to read bearer token: "+err.Error())
 		return
 	}
 
-	// Get identity claims from userinfo endpoint
-	userinfoClaims, err, authFailed := RequestUserinfo(bearerToken, appConfig.UserinfoEndpoint, appConfig.Issuer)
+	// Read proof of possession from request body and verify its signature before requesting the OpenID Provider
+	_, span := tracer.Start(r.Context(), "ParseProofOfPossession")
+	popToken, popClaims, publicKeyJwk, err := ParseProofOfPossessionFromRequestBody(r)
+	if err == nil {
+		err = ValidateProofOfPossessionClaims(popToken, popClaims, appConfig, time.Now())
+	}
+	endSpan(span, err)
 	if err != nil {
-		if authFailed {
-			LogAndSendError(w, http.StatusUnauthorized, "unauthorized", "invalid bearer token", err.Error())
+		recordPopFailure(err)
+		LogAndSendError(w, r, http.StatusForbidden, "forbidden", "invalid proof of possession", "failed to validate proof of possession: "+err.Error())
+		return
+	}
+
+	// Request userinfo and validate access token concurrently
+	result := RequestOpenIdProvider(r.Context(), bearerToken, time.Now())
+	if LogAndSendContextError(w, r) {
+		return
+	}
+	if result.UserinfoErr != nil && (result.IntrospectionErr == nil || !result.IntrospectionFailedFirst) {
+		if result.AuthFailed {
+			LogAndSendError(w, r, http.StatusUnauthorized, "unauthorized", "invalid bearer token", result.UserinfoErr.Error())
 		} else {
-			LogAndSendError(w, http.StatusInternalServerError, "internal server error", "unknown internal server error", err.Error())
+			LogAndSendUpstreamError(w, r, result.UserinfoErr, "failed to request userinfo: "+result.UserinfoErr.Error())
 		}
 		return
 	}
-
-	// Read proof of possession from request body
-	popToken, popClaims, publicKeyJwk, err := ParseProofOfPossessionFromRequestBody(r)
-	if err != nil {
-		LogAndSendError(w, http.StatusInternalServerError, "internal server error", "unknown internal server error", "failed to validate proof of possession: "+err.Error())
+	if result.IntrospectionErr != nil {
+		if result.TokenRejected {
+			LogAndSendError(w, r, http.StatusUnauthorized, "unauthorized", "invalid bearer token", "failed to introspect Access Token: "+result.IntrospectionErr.Error())
+		} else {
+			LogAndSendUpstreamError(w, r, result.IntrospectionErr, "failed to introspect Access Token: "+result.IntrospectionErr.Error())
+		}
 		return
 	}
+	userinfoClaims := result.UserinfoClaims
+	introspection := result.Introspection
 
 	// Validate proof of possession
-	err = ValidateProofOfPossession(popToken, popClaims, userinfoClaims, appConfig, time.Now())
+	ctx, span := tracer.Start(r.Context(), "ValidateProofOfPossession")
+	err = ValidateProofOfPossession(ctx, popToken, popClaims, userinfoClaims, appConfig, time.Now())
+	endSpan(span, err)
+	if LogAndSendContextError(w, r) {
+		return
+	}
 	if err != nil {
-		LogAndSendError(w, http.StatusForbidden, "forbidden", "invalid proof of possession", "failed to validate proof of possession: "+err.Error())
+		recordPopFailure(err)
+		LogAndSendError(w, r, http.StatusForbidden, "forbidden", "invalid proof of possession", "failed to validate proof of possession: "+err.Error())
 		return
 	}
 
-	// Introspect access token
-	accessTokenClaims, err := IntrospectAccessToken(bearerToken, appConfig.TokenIntrospectionEndpoint)
+	// Refuse revoked proof of possession keys
+	keyThumbprint, err := JwkThumbprintFromJson(publicKeyJwk)
 	if err != nil {
-		LogAndSendError(w, http.StatusInternalServerError, "internal server error", "unknown internal server error", "failed to introspect Access Token: "+err.Error())
+		LogAndSendError(w, r, http.StatusInternalServerError, "internal server error", "unknown internal server error", "failed to compute proof of possession key thumbprint: "+err.Error())
+		return
+	}
+	// Revocations by jti and sub only apply to tokens issued before, so only revoked keys are refused at any time
+	revocation, err := appRevocationStore.Check(r.Context(), "", "", keyThumbprint, 0)
+	if err != nil {
+		LogAndSendError(w, r, http.StatusInternalServerError, "internal server error", "unknown internal server error", err.Error())
+		return
+	}
+	if revocation != nil {
+		LogAndSendError(w, r, http.StatusForbidden, "forbidden", "revoked", "refused revoked "+revocation.Type)
 		return
 	}
 
 	// Get the contexts from access token claims
-	contexts, err := GetContexts(accessTokenClaims)
+	contexts, err := GetContexts(introspection)
 	if err != nil {
-		LogAndSendError(w, http.StatusInternalServerError, "internal server error", "unknown internal server error", "failed to get contexts from Access Token: "+err.Error())
+		LogAndSendError(w, r, http.StatusInternalServerError, "internal server error", "unknown internal server error", "failed to get contexts from Access Token: "+err.Error())
 		return
 	}
 
 	// Get the client id from access token claims
-	clientId, clientIdFound := accessTokenClaims["azp"].(string)
-	if !clientIdFound {
-		clientId, clientIdFound = accessTokenClaims["aud"].(string)
-		if !clientIdFound {
-			LogAndSendError(w, http.StatusInternalServerError, "internal server error", "unknown internal server error", "Client ID not present in Access Token")
-			return
-		}
-	}
+	clientId := introspection.Client()
 
 	// Get with_audience parameter from request
 	withAudience, withAudienceFound := popClaims["with_audience"].(bool)
 
+	// Reserve the index of the Identity Certification Token in the status list
+	statusListIndex, err := appRevocationStore.NextStatusListIndex(r.Context())
+	if err != nil {
+		LogAndSendError(w, r, http.StatusInternalServerError, "internal server error", "unknown internal server error", err.Error())
+		return
+	}
+
 	// Generate Identity Certification Token
-	ict, identityClaims, expiresAt, err := GenerateIct(appPrivateKey, appConfig.SigningAlgorithm, popClaims, publicKeyJwk, userinfoClaims, appConfig, contexts, clientId, withAudienceFound && withAudience)
+	signingKey := appKeyRing.ActiveKey()
+	ctx, span = tracer.Start(r.Context(), "GenerateIct", trace.WithAttributes(attribute.StringSlice("ict.contexts", contexts)))
+	ict, identityClaims, ictClaims, err := GenerateIct(ctx, signingKey, popClaims, publicKeyJwk, ClientCertificateThumbprint(r), userinfoClaims, appConfig, contexts, clientId, withAudienceFound && withAudience, statusListIndex, StatusListUri(appConfig))
+	endSpan(span, err)
+	if LogAndSendContextError(w, r) {
+		return
+	}
 	if err != nil {
-		LogAndSendError(w, http.StatusInternalServerError, "internal server error", "unknown internal server error", "failed to generate Identity Certification Token: "+err.Error())
+		LogAndSendError(w, r, http.StatusInternalServerError, "internal server error", "unknown internal server error", "failed to generate Identity Certification Token: "+err.Error())
 		return
 	}
 
+	// Add Identity Certification Token to the status list before returning it, so it can be revoked
+	ctx, span = tracer.Start(r.Context(), "AddStatusListEntry")
+	err = appRevocationStore.AddStatusListEntry(ctx, StatusListEntry{
+		Index:         statusListIndex,
+		Jti:           ictClaims["jti"].(string),
+		Subject:       ictClaims["sub"].(string),
+		KeyThumbprint: keyThumbprint,
+		IssuedAt:      ictClaims["iat"].(int64),
+		ExpiresAt:     ictClaims["exp"].(int64),
+	})
+	endSpan(span, err)
+	if err != nil {
+		LogAndSendError(w, r, http.StatusInternalServerError, "internal server error", "unknown internal server error", err.Error())
+		return
+	}
+
+	// Record Identity Certification Token in audit log before returning it
+	if appAuditSink != nil {
+		ctx, span = tracer.Start(r.Context(), "AppendAuditRecord")
+		err = appendAuditRecord(ctx, ictClaims, identityClaims, clientId, signingKey.KeyId, popToken)
+		endSpan(span, err)
+		if err != nil {
+			LogAndSendError(w, r, http.StatusInternalServerError, "internal server error", "unknown internal server error", "failed to record Identity Certification Token: "+err.Error())
+			return
+		}
+	}
+
+	recordIssuedIct(contexts, signingKey.Algorithm.Alg())
+
 	// Encode response
-	expiresIn := expiresAt - time.Now().Unix()
+	expiresIn := ictClaims["exp"].(int64) - time.Now().Unix()
 	response := IctResponse{
 		IdentityCertificationToken: ict,
 		ExpiresIn:                  int32(expiresIn),
@@ -844,7 +1308,6 @@ func GenIct(w http.ResponseWriter, r *http.Request) {
 
 func IctOptions(w http.ResponseWriter, r *http.Request) {
 	origin := r.Header.Get("Origin")
-	log.Print("origin: " + origin)
 	if origin != "" {
 		w.Header().Set("Access-Control-Allow-Origin", origin)
 	}
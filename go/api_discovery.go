@@ -0,0 +1,35 @@
+/*
+ * OIDC² - Identity Certification Token Endpoint
+ *
+ * Endpoint for OpenID Connect's Identity Certification Token endpoint.
+ *
+ * API version: 0.5.0
+ */
+package ict
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+func GetIctConfiguration(w http.ResponseWriter, r *http.Request) {
+	// Allow browser-based clients to configure themselves
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Methods", "GET")
+
+	// Generate configuration document
+	configuration := IctConfigurationFromAppConfiguration(appConfig, EndpointUrl(), appKeyRing.Algorithms())
+
+	// Write response
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(configuration)
+}
+
+// EndpointUrl returns the configured public URL of the endpoint.
+// It is never derived from the request, since the request's headers are controlled by the client.
+func EndpointUrl() string {
+	return strings.TrimSuffix(appConfig.EndpointUrl, "/")
+}
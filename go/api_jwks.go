@@ -0,0 +1,31 @@
+/*
+ * OIDC² - Identity Certification Token Endpoint
+ *
+ * Endpoint for OpenID Connect's Identity Certification Token endpoint.
+ *
+ * API version: 0.5.0
+ */
+package ict
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Time verifiers may cache the JWK set.
+// Next signing keys are published at least this long before they are activated.
+const jwksCacheLifetime = 300 * time.Second
+
+func GetJwks(w http.ResponseWriter, r *http.Request) {
+	// Allow browser-based clients to verify tokens
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Methods", "GET")
+
+	// Write response
+	w.Header().Set("Content-Type", "application/jwk-set+json; charset=UTF-8")
+	w.Header().Set("Cache-Control", "public, max-age="+strconv.Itoa(int(jwksCacheLifetime.Seconds())))
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(appKeyRing.Jwks())
+}
@@ -0,0 +1,93 @@
+/*
+ * OIDC² - Identity Certification Token Endpoint
+ *
+ * Endpoint for OpenID Connect's Identity Certification Token endpoint.
+ *
+ * API version: 0.5.0
+ */
+package ict
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"ict/verify"
+)
+
+// PostStatus responds whether an Identity Certification Token is valid or revoked.
+// Unlike PostVerify, it does not require a Proof of Possession Token, e.g., to recheck a token received earlier.
+func PostStatus(w http.ResponseWriter, r *http.Request) {
+	// Parse request body
+	var request StatusRequest
+	err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64*1024)).Decode(&request)
+	if err != nil {
+		LogAndSendError(w, r, http.StatusBadRequest, "bad request", "invalid status request", "failed to parse status request: "+err.Error())
+		return
+	}
+	if request.IdentityCertificationToken == "" {
+		LogAndSendError(w, r, http.StatusBadRequest, "bad request", "identity certification token required", "status request incomplete")
+		return
+	}
+
+	// Verify token
+	verifier := verify.IctVerifier{
+		Issuer: appConfig.Issuer,
+		Keys:   appKeyRing.PublicKey,
+	}
+	response := StatusResponse{Status: IctStatusValid}
+	verifiedIct, err := verifier.VerifyIct(request.IdentityCertificationToken, verify.IctVerificationOptions{Now: time.Now()})
+	if err != nil {
+		response = StatusResponse{
+			Status:           IctStatusInvalid,
+			ErrorDescription: err.Error(),
+		}
+	} else {
+		// Check revocations
+		revocation, err := CheckIctRevocation(r.Context(), verifiedIct)
+		if err != nil {
+			LogAndSendError(w, r, http.StatusInternalServerError, "internal server error", "unknown internal server error", "failed to check revocation: "+err.Error())
+			return
+		}
+		if revocation != nil {
+			response = StatusResponse{
+				Status:    IctStatusRevoked,
+				RevokedAt: revocation.RevokedAt,
+			}
+		}
+	}
+
+	// Write response
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+// GetStatusList responds with the Status List Token, which contains the revocation status of all unexpired Identity Certification Tokens
+// at the index of their 'status' claim, as defined by the OAuth Token Status List draft.
+func GetStatusList(w http.ResponseWriter, r *http.Request) {
+	// Allow browser-based clients to check tokens
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Methods", "GET")
+
+	// Generate Status List Token
+	now := time.Now()
+	size, revoked, err := appRevocationStore.RevokedStatusListIndices(r.Context(), now)
+	if err != nil {
+		LogAndSendError(w, r, http.StatusInternalServerError, "internal server error", "unknown internal server error", err.Error())
+		return
+	}
+	statusListToken, err := GenerateStatusListToken(appKeyRing.ActiveKey(), StatusListUri(appConfig), size, revoked, now)
+	if err != nil {
+		LogAndSendError(w, r, http.StatusInternalServerError, "internal server error", "unknown internal server error", err.Error())
+		return
+	}
+
+	// Write response
+	w.Header().Set("Content-Type", "application/statuslist+jwt")
+	w.Header().Set("Cache-Control", "public, max-age="+strconv.Itoa(int(statusListTokenLifetime.Seconds())))
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(statusListToken))
+}
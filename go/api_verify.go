@@ -0,0 +1,99 @@
+/*
+ * OIDC² - Identity Certification Token Endpoint
+ *
+ * Endpoint for OpenID Connect's Identity Certification Token endpoint.
+ *
+ * API version: 0.5.0
+ */
+package ict
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+
+	"ict/verify"
+)
+
+func PostVerify(w http.ResponseWriter, r *http.Request) {
+	// Parse request body
+	var request VerificationRequest
+	err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64*1024)).Decode(&request)
+	if err != nil {
+		LogAndSendError(w, r, http.StatusBadRequest, "bad request", "invalid verification request", "failed to parse verification request: "+err.Error())
+		return
+	}
+	if request.IdentityCertificationToken == "" || request.ProofOfPossessionToken == "" || request.Audience == "" {
+		LogAndSendError(w, r, http.StatusBadRequest, "bad request", "identity certification token, proof of possession token and audience required", "verification request incomplete")
+		return
+	}
+
+	// Verify tokens
+	verifier := verify.IctVerifier{
+		Issuer: appConfig.Issuer,
+		Keys:   appKeyRing.PublicKey,
+	}
+	options := verify.IctVerificationOptions{
+		Audience: request.Audience,
+		Contexts: request.E2eAuthContexts,
+		Now:      time.Now(),
+	}
+	verifiedIct, err := verifier.VerifyIct(request.IdentityCertificationToken, options)
+	var popClaims jwt.MapClaims
+	if err == nil {
+		popClaims, err = verifier.VerifyProofOfPossession(verifiedIct, request.ProofOfPossessionToken, options)
+	}
+
+	// Reject replayed proof of possession tokens
+	if err == nil {
+		jti, _ := StringFromJson(popClaims, "jti")
+		expUnix, _ := Int64FromJson(popClaims, "exp")
+		nonceErr := appNonceStore.Add(r.Context(), jti, time.Unix(expUnix, 0))
+		if errors.Is(nonceErr, ErrNonceReplayed) {
+			err = errors.New("invalid proof of possession token: token already used")
+		} else if nonceErr != nil {
+			LogAndSendError(w, r, http.StatusInternalServerError, "internal server error", "unknown internal server error", "failed to store nonce: "+nonceErr.Error())
+			return
+		}
+	}
+
+	// Reject revoked tokens
+	if err == nil {
+		revocation, revocationErr := CheckIctRevocation(r.Context(), verifiedIct)
+		if revocationErr != nil {
+			LogAndSendError(w, r, http.StatusInternalServerError, "internal server error", "unknown internal server error", "failed to check revocation: "+revocationErr.Error())
+			return
+		}
+		if revocation != nil {
+			err = errors.New("identity certification token revoked")
+		}
+	}
+
+	// Encode response
+	var response VerificationResponse
+	if err != nil {
+		slog.InfoContext(r.Context(), "verification failed", "error", err)
+		response = VerificationResponse{
+			Valid:            false,
+			ErrorDescription: err.Error(),
+		}
+	} else {
+		response = VerificationResponse{
+			Valid:           true,
+			Subject:         verifiedIct.Subject,
+			E2eAuthContexts: verifiedIct.Contexts,
+			ExpiresAt:       verifiedIct.ExpiresAt.Unix(),
+			Claims:          verifiedIct.Claims,
+		}
+	}
+
+	// Write response
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
@@ -0,0 +1,268 @@
+/*
+ * OIDC² - Identity Certification Token Endpoint
+ *
+ * Endpoint for OpenID Connect's Identity Certification Token endpoint.
+ *
+ * API version: 0.5.0
+ */
+package ict
+
+import (
+	"context"
+	"crypto"
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// Audit log of issued Identity Certification Tokens, nil if disabled.
+var appAuditSink AuditSink
+
+// Previous hash of the first record of an audit log.
+var AuditGenesisHash = strings.Repeat("0", 64)
+
+// AuditSink is an append-only log of issued Identity Certification Tokens.
+// Records are chained by hashes, so modified, inserted or removed records can be detected.
+// Without an HMAC key, anyone who can write the log can recompute the whole chain, and records removed from the end
+// are never detected, unless the latest hash is kept outside of the audit log and passed to Verify as head.
+type AuditSink interface {
+	// Append assigns the next sequence number and the hash chain to record and persists it.
+	Append(ctx context.Context, record AuditRecord) (AuditRecord, error)
+	// Query returns the records of subject and with jti in order. Empty values match all records.
+	Query(ctx context.Context, subject string, jti string) ([]AuditRecord, error)
+	// Verify checks the hash chain of all records and returns the number of verified records and the hash of the latest one.
+	// If head is not empty, the chain must contain a record with hash head, so records removed from the end are detected.
+	Verify(ctx context.Context, head string) (int, string, error)
+	// Close releases the file or database of the audit sink.
+	Close() error
+}
+
+// Names of the supported audit sinks.
+const (
+	AuditSinkNone   = "none"
+	AuditSinkFile   = "file"
+	AuditSinkSqlite = "sqlite"
+)
+
+// NewAuditSink creates the configured audit sink, which stores records in db if the sink is 'sqlite'.
+// It returns nil if auditing is disabled.
+func NewAuditSink(config AppConfiguration, db *sql.DB) (AuditSink, error) {
+	key, err := readAuditHmacKey(config)
+	if err != nil {
+		return nil, err
+	}
+	switch config.AuditSink {
+	case AuditSinkFile:
+		return NewFileAuditSink(config.AuditFile, key)
+	case AuditSinkSqlite:
+		return NewSqliteAuditSink(db, key), nil
+	default:
+		return nil, nil
+	}
+}
+
+// OpenAuditSink opens the existing audit log of the configured audit sink to query and verify it, e.g., from the command line.
+// The database is opened without preparing it, so the database of a running server is not modified.
+// The returned sink must be closed.
+func OpenAuditSink(config AppConfiguration) (AuditSink, error) {
+	key, err := readAuditHmacKey(config)
+	if err != nil {
+		return nil, err
+	}
+	switch config.AuditSink {
+	case AuditSinkFile:
+		if _, err := os.Stat(config.AuditFile); err != nil {
+			return nil, errors.New("failed to open audit log file: " + err.Error())
+		}
+		return NewFileAuditSink(config.AuditFile, key)
+	case AuditSinkSqlite:
+		db, err := openDatabase(config.DatabaseFile)
+		if err != nil {
+			return nil, err
+		}
+		sink := NewSqliteAuditSink(db, key)
+		sink.closeDb = true
+		return sink, nil
+	default:
+		return nil, errors.New("audit log is disabled by audit sink '" + config.AuditSink + "'")
+	}
+}
+
+// ReadAuditHmacKey reads the base64 encoded 256 bit key of the audit log's hash chain from fileName,
+// e.g., generated with 'openssl rand -base64 32'.
+func ReadAuditHmacKey(fileName string) ([]byte, error) {
+	return readSecretKeyFile(fileName, "audit HMAC key")
+}
+
+// readAuditHmacKey reads the configured audit HMAC key, or returns nil if none is configured.
+func readAuditHmacKey(config AppConfiguration) ([]byte, error) {
+	if config.AuditHmacKeyFile == "" {
+		return nil, nil
+	}
+	return ReadAuditHmacKey(config.AuditHmacKeyFile)
+}
+
+// auditRecordHash computes the hash of a record, which covers all members except the hash itself.
+// The hash is an HMAC-SHA256 if key is not nil, so it cannot be recomputed without the key.
+func auditRecordHash(key []byte, record AuditRecord) (string, error) {
+	record.Hash = ""
+	data, err := json.Marshal(record)
+	if err != nil {
+		return "", errors.New("failed to encode audit record: " + err.Error())
+	}
+	if key != nil {
+		mac := hmac.New(sha256.New, key)
+		mac.Write(data)
+		return hex.EncodeToString(mac.Sum(nil)), nil
+	}
+	hash := sha256.Sum256(data)
+	return hex.EncodeToString(hash[:]), nil
+}
+
+// sealAuditRecord chains record to the previous record, or to the genesis hash if previous is nil.
+func sealAuditRecord(key []byte, record AuditRecord, previous *AuditRecord) (AuditRecord, error) {
+	record.Sequence = 1
+	record.PreviousHash = AuditGenesisHash
+	if previous != nil {
+		record.Sequence = previous.Sequence + 1
+		record.PreviousHash = previous.Hash
+	}
+
+	hash, err := auditRecordHash(key, record)
+	if err != nil {
+		return AuditRecord{}, err
+	}
+	record.Hash = hash
+	return record, nil
+}
+
+// auditChainVerifier verifies the hash chain of records in order.
+type auditChainVerifier struct {
+	key      []byte
+	head     string
+	previous *AuditRecord
+	headSeen bool
+}
+
+// Next verifies that record is intact and follows the previously verified record.
+func (verifier *auditChainVerifier) Next(record AuditRecord) error {
+	expectedSequence := uint64(1)
+	expectedPreviousHash := AuditGenesisHash
+	if verifier.previous != nil {
+		expectedSequence = verifier.previous.Sequence + 1
+		expectedPreviousHash = verifier.previous.Hash
+	}
+
+	if record.Sequence != expectedSequence {
+		return fmt.Errorf("audit record %d: expected sequence number %d, records were removed or inserted", record.Sequence, expectedSequence)
+	}
+	if record.PreviousHash != expectedPreviousHash {
+		return fmt.Errorf("audit record %d: previous hash does not match, the previous record was modified", record.Sequence)
+	}
+	hash, err := auditRecordHash(verifier.key, record)
+	if err != nil {
+		return err
+	}
+	if record.Hash != hash {
+		return fmt.Errorf("audit record %d: hash does not match, the record was modified or the audit HMAC key is wrong", record.Sequence)
+	}
+
+	verifier.previous = &record
+	if record.Hash == verifier.head {
+		verifier.headSeen = true
+	}
+	return nil
+}
+
+// Finish verifies that the expected head of the chain was found, if any, and returns the hash of the latest record.
+func (verifier *auditChainVerifier) Finish() (string, error) {
+	latestHash := AuditGenesisHash
+	if verifier.previous != nil {
+		latestHash = verifier.previous.Hash
+	}
+	if verifier.head != "" && !verifier.headSeen {
+		return latestHash, errors.New("audit record with hash '" + verifier.head + "' not found, records were removed from the end")
+	}
+	return latestHash, nil
+}
+
+// NewAuditRecord composes the audit record of an issued Identity Certification Token from its claims.
+func NewAuditRecord(ictClaims map[string]interface{}, identityClaims []string, clientId string, signingKeyId string, popKey crypto.PublicKey, requestId string) (AuditRecord, error) {
+	jti, err := StringFromJson(ictClaims, "jti")
+	if err != nil {
+		return AuditRecord{}, errors.New("failed to read jti: " + err.Error())
+	}
+	subject, err := StringFromJson(ictClaims, "sub")
+	if err != nil {
+		return AuditRecord{}, errors.New("failed to read subject: " + err.Error())
+	}
+	issuedAt, err := Int64FromJson(ictClaims, "iat")
+	if err != nil {
+		return AuditRecord{}, errors.New("failed to read issuance time: " + err.Error())
+	}
+	expiresAt, err := Int64FromJson(ictClaims, "exp")
+	if err != nil {
+		return AuditRecord{}, errors.New("failed to read expiration time: " + err.Error())
+	}
+	contexts, _ := ictClaims["ctx"].([]string)
+	if contexts == nil {
+		contexts = []string{}
+	}
+
+	// Identify the confirmation key by its thumbprint
+	keyThumbprint, err := JwkThumbprint(popKey)
+	if err != nil {
+		return AuditRecord{}, errors.New("failed to compute confirmation key thumbprint: " + err.Error())
+	}
+	var certificateThumbprint string
+	if confirmation, ok := ictClaims["cnf"].(map[string]interface{}); ok {
+		certificateThumbprint, _ = confirmation["x5t#S256"].(string)
+	}
+
+	// Sort claim names, which are in random order
+	claims := append([]string{}, identityClaims...)
+	sort.Strings(claims)
+
+	return AuditRecord{
+		IssuedAt:              issuedAt,
+		ExpiresAt:             expiresAt,
+		Lifetime:              expiresAt - issuedAt,
+		Jti:                   jti,
+		Subject:               subject,
+		ClientId:              clientId,
+		E2eAuthContexts:       contexts,
+		Claims:                claims,
+		KeyThumbprint:         keyThumbprint,
+		CertificateThumbprint: certificateThumbprint,
+		SigningKeyId:          signingKeyId,
+		RequestId:             requestId,
+	}, nil
+}
+
+// appendAuditRecord records an issued Identity Certification Token, which is confirmed by the key of popToken, in the audit sink.
+func appendAuditRecord(ctx context.Context, ictClaims jwt.MapClaims, identityClaims []string, clientId string, signingKeyId string, popToken *jwt.Token) error {
+	popKey, _, err := PublicKeyFromJwt(popToken)
+	if err != nil {
+		return errors.New("failed to read confirmation key: " + err.Error())
+	}
+	record, err := NewAuditRecord(ictClaims, identityClaims, clientId, signingKeyId, popKey, RequestIdFromContext(ctx))
+	if err != nil {
+		return err
+	}
+	record, err = appAuditSink.Append(ctx, record)
+	if err != nil {
+		return err
+	}
+	slog.DebugContext(ctx, "audit record appended", "seq", record.Sequence, "jti", record.Jti)
+	return nil
+}
@@ -0,0 +1,147 @@
+/*
+ * OIDC² - Identity Certification Token Endpoint
+ *
+ * Endpoint for OpenID Connect's Identity Certification Token endpoint.
+ *
+ * API version: 0.5.0
+ */
+package ict
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"strconv"
+	"sync"
+)
+
+// Maximum length of a line of an audit log file.
+const maxAuditLineLength = 1024 * 1024
+
+// FileAuditSink appends records as JSON lines to a file.
+// The file must not be shared by multiple instances, since each instance continues its own hash chain.
+type FileAuditSink struct {
+	path  string
+	key   []byte
+	mutex sync.Mutex
+	file  *os.File
+	last  *AuditRecord
+}
+
+// NewFileAuditSink opens or creates the audit log file at path and continues its hash chain, which is keyed with key if not nil.
+func NewFileAuditSink(path string, key []byte) (*FileAuditSink, error) {
+	sink := &FileAuditSink{path: path, key: key}
+
+	// Read the last record to continue the hash chain
+	err := sink.scan(context.Background(), func(record AuditRecord) error {
+		sink.last = &record
+		return nil
+	})
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, errors.New("failed to open audit log file '" + path + "': " + err.Error())
+	}
+	sink.file = file
+	return sink, nil
+}
+
+func (sink *FileAuditSink) Append(ctx context.Context, record AuditRecord) (AuditRecord, error) {
+	if err := ctx.Err(); err != nil {
+		return AuditRecord{}, errors.New("failed to append audit record: " + err.Error())
+	}
+	sink.mutex.Lock()
+	defer sink.mutex.Unlock()
+
+	record, err := sealAuditRecord(sink.key, record, sink.last)
+	if err != nil {
+		return AuditRecord{}, err
+	}
+	line, err := json.Marshal(record)
+	if err != nil {
+		return AuditRecord{}, errors.New("failed to encode audit record: " + err.Error())
+	}
+
+	// Write the record with a single write call and flush it to disk before the ICT is returned
+	if _, err := sink.file.Write(append(line, '\n')); err != nil {
+		return AuditRecord{}, errors.New("failed to write audit log file '" + sink.path + "': " + err.Error())
+	}
+	if err := sink.file.Sync(); err != nil {
+		return AuditRecord{}, errors.New("failed to sync audit log file '" + sink.path + "': " + err.Error())
+	}
+	sink.last = &record
+	return record, nil
+}
+
+func (sink *FileAuditSink) Query(ctx context.Context, subject string, jti string) ([]AuditRecord, error) {
+	records := []AuditRecord{}
+	err := sink.scan(ctx, func(record AuditRecord) error {
+		if (subject == "" || record.Subject == subject) && (jti == "" || record.Jti == jti) {
+			records = append(records, record)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return records, nil
+}
+
+func (sink *FileAuditSink) Verify(ctx context.Context, head string) (int, string, error) {
+	verifier := auditChainVerifier{key: sink.key, head: head}
+	count := 0
+	err := sink.scan(ctx, func(record AuditRecord) error {
+		if err := verifier.Next(record); err != nil {
+			return err
+		}
+		count++
+		return nil
+	})
+	if err != nil {
+		return count, "", err
+	}
+	latestHash, err := verifier.Finish()
+	return count, latestHash, err
+}
+
+func (sink *FileAuditSink) Close() error {
+	sink.mutex.Lock()
+	defer sink.mutex.Unlock()
+	if sink.file == nil {
+		return nil
+	}
+	return sink.file.Close()
+}
+
+// scan calls fn with each record of the audit log file in order.
+func (sink *FileAuditSink) scan(ctx context.Context, fn func(record AuditRecord) error) error {
+	file, err := os.Open(sink.path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), maxAuditLineLength)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		if err := ctx.Err(); err != nil {
+			return errors.New("failed to read audit log file '" + sink.path + "': " + err.Error())
+		}
+		var record AuditRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			return errors.New("failed to parse line " + strconv.Itoa(lineNumber) + " of audit log file '" + sink.path + "': " + err.Error())
+		}
+		if err := fn(record); err != nil {
+			return err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return errors.New("failed to read audit log file '" + sink.path + "': " + err.Error())
+	}
+	return nil
+}
@@ -0,0 +1,141 @@
+/*
+ * OIDC² - Identity Certification Token Endpoint
+ *
+ * Endpoint for OpenID Connect's Identity Certification Token endpoint.
+ *
+ * API version: 0.5.0
+ */
+package ict
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"sync"
+)
+
+// SqliteAuditSink stores records in the 'audit_records' table of a SQLite database.
+// The sequence number is the primary key, so concurrent appends of multiple instances cannot fork the hash chain.
+type SqliteAuditSink struct {
+	db      *sql.DB
+	key     []byte
+	mutex   sync.Mutex
+	closeDb bool
+}
+
+// NewSqliteAuditSink creates an audit sink in db whose hash chain is keyed with key if not nil.
+func NewSqliteAuditSink(db *sql.DB, key []byte) *SqliteAuditSink {
+	return &SqliteAuditSink{db: db, key: key}
+}
+
+func (sink *SqliteAuditSink) Append(ctx context.Context, record AuditRecord) (AuditRecord, error) {
+	sink.mutex.Lock()
+	defer sink.mutex.Unlock()
+
+	tx, err := sink.db.BeginTx(ctx, nil)
+	if err != nil {
+		return AuditRecord{}, errors.New("failed to begin audit transaction: " + err.Error())
+	}
+	defer tx.Rollback()
+
+	// Continue the hash chain of the last record
+	var previous *AuditRecord
+	var previousData string
+	err = tx.QueryRowContext(ctx, "SELECT record FROM audit_records ORDER BY seq DESC LIMIT 1").Scan(&previousData)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return AuditRecord{}, errors.New("failed to read last audit record: " + err.Error())
+	}
+	if err == nil {
+		previous = &AuditRecord{}
+		if err := json.Unmarshal([]byte(previousData), previous); err != nil {
+			return AuditRecord{}, errors.New("failed to parse last audit record: " + err.Error())
+		}
+	}
+
+	record, err = sealAuditRecord(sink.key, record, previous)
+	if err != nil {
+		return AuditRecord{}, err
+	}
+	data, err := json.Marshal(record)
+	if err != nil {
+		return AuditRecord{}, errors.New("failed to encode audit record: " + err.Error())
+	}
+	_, err = tx.ExecContext(ctx, "INSERT INTO audit_records (seq, jti, sub, record, hash, prev_hash) VALUES (?, ?, ?, ?, ?, ?)", record.Sequence, record.Jti, record.Subject, string(data), record.Hash, record.PreviousHash)
+	if err != nil {
+		return AuditRecord{}, errors.New("failed to insert audit record " + strconv.FormatUint(record.Sequence, 10) + ": " + err.Error())
+	}
+	if err := tx.Commit(); err != nil {
+		return AuditRecord{}, errors.New("failed to commit audit record " + strconv.FormatUint(record.Sequence, 10) + ": " + err.Error())
+	}
+	return record, nil
+}
+
+func (sink *SqliteAuditSink) Query(ctx context.Context, subject string, jti string) ([]AuditRecord, error) {
+	records := []AuditRecord{}
+	err := sink.scan(ctx, "SELECT seq, jti, sub, record, hash, prev_hash FROM audit_records WHERE (? = '' OR sub = ?) AND (? = '' OR jti = ?) ORDER BY seq", func(record AuditRecord) error {
+		records = append(records, record)
+		return nil
+	}, subject, subject, jti, jti)
+	if err != nil {
+		return nil, err
+	}
+	return records, nil
+}
+
+func (sink *SqliteAuditSink) Verify(ctx context.Context, head string) (int, string, error) {
+	verifier := auditChainVerifier{key: sink.key, head: head}
+	count := 0
+	err := sink.scan(ctx, "SELECT seq, jti, sub, record, hash, prev_hash FROM audit_records ORDER BY seq", func(record AuditRecord) error {
+		if err := verifier.Next(record); err != nil {
+			return err
+		}
+		count++
+		return nil
+	})
+	if err != nil {
+		return count, "", err
+	}
+	latestHash, err := verifier.Finish()
+	return count, latestHash, err
+}
+
+func (sink *SqliteAuditSink) Close() error {
+	if !sink.closeDb {
+		return nil
+	}
+	return sink.db.Close()
+}
+
+// scan calls fn with each record selected by query in order.
+// Records whose indexed columns differ from their JSON encoding were modified and are rejected.
+func (sink *SqliteAuditSink) scan(ctx context.Context, query string, fn func(record AuditRecord) error, args ...interface{}) error {
+	rows, err := sink.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return errors.New("failed to query audit records: " + err.Error())
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var sequence uint64
+		var jti, subject, data, hash, previousHash string
+		if err := rows.Scan(&sequence, &jti, &subject, &data, &hash, &previousHash); err != nil {
+			return errors.New("failed to read audit record: " + err.Error())
+		}
+		var record AuditRecord
+		if err := json.Unmarshal([]byte(data), &record); err != nil {
+			return errors.New("failed to parse audit record " + strconv.FormatUint(sequence, 10) + ": " + err.Error())
+		}
+		if record.Sequence != sequence || record.Jti != jti || record.Subject != subject || record.Hash != hash || record.PreviousHash != previousHash {
+			return errors.New("audit record " + strconv.FormatUint(sequence, 10) + ": columns do not match the record, the record was modified")
+		}
+		if err := fn(record); err != nil {
+			return err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return errors.New("failed to read audit records: " + err.Error())
+	}
+	return nil
+}
@@ -0,0 +1,220 @@
+/*
+ * OIDC² - Identity Certification Token Endpoint
+ *
+ * Endpoint for OpenID Connect's Identity Certification Token endpoint.
+ *
+ * API version: 0.5.0
+ */
+package ict
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+// testAuditStorage creates audit sinks on the same storage and reads and replaces its raw records, as an attacker with write access could.
+type testAuditStorage struct {
+	open  func(t *testing.T, key []byte) AuditSink
+	read  func(t *testing.T) []AuditRecord
+	write func(t *testing.T, records []AuditRecord)
+}
+
+func newTestFileAuditStorage(t *testing.T) testAuditStorage {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	return testAuditStorage{
+		open: func(t *testing.T, key []byte) AuditSink {
+			sink, err := NewFileAuditSink(path, key)
+			if err != nil {
+				t.Fatalf("NewFileAuditSink() = %v, want nil", err)
+			}
+			t.Cleanup(func() { sink.Close() })
+			return sink
+		},
+		read: func(t *testing.T) []AuditRecord {
+			file, err := os.Open(path)
+			if err != nil {
+				t.Fatalf("failed to open audit file: %v", err)
+			}
+			defer file.Close()
+			records := []AuditRecord{}
+			scanner := bufio.NewScanner(file)
+			for scanner.Scan() {
+				var record AuditRecord
+				if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+					t.Fatalf("failed to parse audit record: %v", err)
+				}
+				records = append(records, record)
+			}
+			return records
+		},
+		write: func(t *testing.T, records []AuditRecord) {
+			data := []byte{}
+			for _, record := range records {
+				line, _ := json.Marshal(record)
+				data = append(append(data, line...), '\n')
+			}
+			if err := os.WriteFile(path, data, 0600); err != nil {
+				t.Fatalf("failed to write audit file: %v", err)
+			}
+		},
+	}
+}
+
+func newTestSqliteAuditStorage(t *testing.T) testAuditStorage {
+	db := newTestDatabase(t)
+	return testAuditStorage{
+		open: func(t *testing.T, key []byte) AuditSink {
+			return NewSqliteAuditSink(db, key)
+		},
+		read: func(t *testing.T) []AuditRecord {
+			rows, err := db.Query("SELECT record FROM audit_records ORDER BY seq")
+			if err != nil {
+				t.Fatalf("failed to read audit records: %v", err)
+			}
+			defer rows.Close()
+			records := []AuditRecord{}
+			for rows.Next() {
+				var data string
+				rows.Scan(&data)
+				var record AuditRecord
+				if err := json.Unmarshal([]byte(data), &record); err != nil {
+					t.Fatalf("failed to parse audit record: %v", err)
+				}
+				records = append(records, record)
+			}
+			return records
+		},
+		write: func(t *testing.T, records []AuditRecord) {
+			if _, err := db.Exec("DELETE FROM audit_records"); err != nil {
+				t.Fatalf("failed to delete audit records: %v", err)
+			}
+			for _, record := range records {
+				data, _ := json.Marshal(record)
+				if _, err := db.Exec("INSERT INTO audit_records (seq, jti, sub, record, hash, prev_hash) VALUES (?, ?, ?, ?, ?, ?)", record.Sequence, record.Jti, record.Subject, string(data), record.Hash, record.PreviousHash); err != nil {
+					t.Fatalf("failed to write audit record: %v", err)
+				}
+			}
+		},
+	}
+}
+
+// appendTestAuditRecords appends three records and returns the latest hash reported by Verify.
+func appendTestAuditRecords(t *testing.T, sink AuditSink) string {
+	t.Helper()
+	ctx := context.Background()
+	for i := 1; i <= 3; i++ {
+		record := AuditRecord{IssuedAt: 1700000000, Jti: "jti-" + strconv.Itoa(i), Subject: "alice", KeyThumbprint: "thumbprint"}
+		if _, err := sink.Append(ctx, record); err != nil {
+			t.Fatalf("Append() = %v, want nil", err)
+		}
+	}
+	count, latestHash, err := sink.Verify(ctx, "")
+	if err != nil || count != 3 {
+		t.Fatalf("Verify() of intact audit log = %d, %v, want 3 records", count, err)
+	}
+	return latestHash
+}
+
+// resealTestAuditRecords recomputes the chain of records with key, as an attacker who modified records would.
+func resealTestAuditRecords(t *testing.T, key []byte, records []AuditRecord) []AuditRecord {
+	t.Helper()
+	resealed := []AuditRecord{}
+	var previous *AuditRecord
+	for _, record := range records {
+		record, err := sealAuditRecord(key, record, previous)
+		if err != nil {
+			t.Fatalf("sealAuditRecord() = %v, want nil", err)
+		}
+		resealed = append(resealed, record)
+		previous = &resealed[len(resealed)-1]
+	}
+	return resealed
+}
+
+func TestAuditSinkDetectsTampering(t *testing.T) {
+	storages := map[string]func(t *testing.T) testAuditStorage{
+		"file":   newTestFileAuditStorage,
+		"sqlite": newTestSqliteAuditStorage,
+	}
+	key := newTestKeyEncryptionKey(t)
+	tests := []struct {
+		name string
+		// tamper modifies the raw records and returns the head to verify against.
+		tamper  func(t *testing.T, records []AuditRecord, latestHash string) ([]AuditRecord, string)
+		wantErr bool
+	}{
+		{"intact", func(t *testing.T, records []AuditRecord, latestHash string) ([]AuditRecord, string) {
+			return records, latestHash
+		}, false},
+		{"modified record", func(t *testing.T, records []AuditRecord, latestHash string) ([]AuditRecord, string) {
+			records[1].Subject = "mallory"
+			return records, ""
+		}, true},
+		{"removed record", func(t *testing.T, records []AuditRecord, latestHash string) ([]AuditRecord, string) {
+			return append(records[:1], records[2:]...), ""
+		}, true},
+		{"truncated end with head", func(t *testing.T, records []AuditRecord, latestHash string) ([]AuditRecord, string) {
+			return records[:2], latestHash
+		}, true},
+		{"rewritten chain without key", func(t *testing.T, records []AuditRecord, latestHash string) ([]AuditRecord, string) {
+			records[1].Subject = "mallory"
+			return resealTestAuditRecords(t, nil, records), ""
+		}, true},
+	}
+	for storageName, newStorage := range storages {
+		for _, test := range tests {
+			t.Run(storageName+"/"+test.name, func(t *testing.T) {
+				storage := newStorage(t)
+				latestHash := appendTestAuditRecords(t, storage.open(t, key))
+
+				records, head := test.tamper(t, storage.read(t), latestHash)
+				storage.write(t, records)
+				_, _, err := storage.open(t, key).Verify(context.Background(), head)
+				if test.wantErr && err == nil {
+					t.Fatalf("Verify() of tampered audit log = nil, want error")
+				}
+				if !test.wantErr && err != nil {
+					t.Fatalf("Verify() = %v, want nil", err)
+				}
+			})
+		}
+	}
+}
+
+func TestAuditSinkWithoutKeyDetectsTruncationOnlyWithHead(t *testing.T) {
+	storage := newTestFileAuditStorage(t)
+	latestHash := appendTestAuditRecords(t, storage.open(t, nil))
+
+	// Records removed from the end are only detected with the previously verified head
+	storage.write(t, storage.read(t)[:2])
+	if _, _, err := storage.open(t, nil).Verify(context.Background(), ""); err != nil {
+		t.Fatalf("Verify() of truncated chain without head = %v, want nil", err)
+	}
+	if _, _, err := storage.open(t, nil).Verify(context.Background(), latestHash); err == nil {
+		t.Fatalf("Verify() of truncated chain with head = nil, want error")
+	}
+
+	// Without a key, a rewritten chain cannot be detected, which is the documented limit
+	records := storage.read(t)
+	records[1].Subject = "mallory"
+	storage.write(t, resealTestAuditRecords(t, nil, records))
+	if _, _, err := storage.open(t, nil).Verify(context.Background(), ""); err != nil {
+		t.Fatalf("Verify() of rewritten unkeyed chain = %v, want nil", err)
+	}
+}
+
+func TestOpenAuditSinkDoesNotPrepareDatabase(t *testing.T) {
+	config := AppConfiguration{AuditSink: AuditSinkSqlite, DatabaseFile: filepath.Join(t.TempDir(), "ict.db")}
+	if sink, err := OpenAuditSink(config); err == nil {
+		sink.Close()
+		t.Fatalf("OpenAuditSink() of missing database = nil, want error")
+	}
+	if _, err := os.Stat(config.DatabaseFile); !os.IsNotExist(err) {
+		t.Fatalf("OpenAuditSink() created the database")
+	}
+}
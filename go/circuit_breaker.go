@@ -0,0 +1,124 @@
+/*
+ * OIDC² - Identity Certification Token Endpoint
+ *
+ * Endpoint for OpenID Connect's Identity Certification Token endpoint.
+ *
+ * API version: 0.5.0
+ */
+package ict
+
+import (
+	"sync"
+	"time"
+)
+
+// States of a circuit breaker.
+const (
+	CircuitBreakerClosed   = "closed"
+	CircuitBreakerOpen     = "open"
+	CircuitBreakerHalfOpen = "half-open"
+)
+
+// CircuitBreaker rejects requests to an upstream endpoint after consecutive failures.
+// After the open period, a single trial request is allowed which closes the circuit on success.
+type CircuitBreaker struct {
+	failureThreshold int
+	openPeriod       time.Duration
+	mutex            sync.Mutex
+	state            string
+	failures         int
+	openedAt         time.Time
+	trialPending     bool
+}
+
+// NewCircuitBreaker creates a circuit breaker which opens after failureThreshold consecutive failures for openPeriod.
+// A failureThreshold of 0 disables the circuit breaker.
+func NewCircuitBreaker(failureThreshold int, openPeriod time.Duration) *CircuitBreaker {
+	return &CircuitBreaker{
+		failureThreshold: failureThreshold,
+		openPeriod:       openPeriod,
+		state:            CircuitBreakerClosed,
+	}
+}
+
+// Allow returns whether a request may be sent at time now.
+// If not, it returns the duration after which a request will be allowed again.
+func (breaker *CircuitBreaker) Allow(now time.Time) (bool, time.Duration) {
+	breaker.mutex.Lock()
+	defer breaker.mutex.Unlock()
+
+	switch breaker.state {
+	case CircuitBreakerOpen:
+		reopensAt := breaker.openedAt.Add(breaker.openPeriod)
+		if now.Before(reopensAt) {
+			return false, reopensAt.Sub(now)
+		}
+		breaker.state = CircuitBreakerHalfOpen
+		breaker.trialPending = true
+		return true, 0
+	case CircuitBreakerHalfOpen:
+		if breaker.trialPending {
+			return false, time.Second
+		}
+		breaker.trialPending = true
+		return true, 0
+	default:
+		return true, 0
+	}
+}
+
+// Success records a successful request and closes the circuit.
+func (breaker *CircuitBreaker) Success() {
+	breaker.mutex.Lock()
+	defer breaker.mutex.Unlock()
+
+	breaker.state = CircuitBreakerClosed
+	breaker.failures = 0
+	breaker.trialPending = false
+}
+
+// Failure records a failed request at time now and opens the circuit if the threshold is reached.
+func (breaker *CircuitBreaker) Failure(now time.Time) {
+	breaker.mutex.Lock()
+	defer breaker.mutex.Unlock()
+
+	breaker.failures++
+	breaker.trialPending = false
+	if breaker.failureThreshold <= 0 {
+		return
+	}
+	if breaker.state == CircuitBreakerHalfOpen || breaker.failures >= breaker.failureThreshold {
+		breaker.state = CircuitBreakerOpen
+		breaker.openedAt = now
+	}
+}
+
+// Cancel records an aborted request, whose outcome is unknown.
+func (breaker *CircuitBreaker) Cancel() {
+	breaker.mutex.Lock()
+	defer breaker.mutex.Unlock()
+
+	breaker.trialPending = false
+}
+
+// RetryAfter returns the duration after which the open circuit allows a request again at time now, or 0 if it is not open.
+func (breaker *CircuitBreaker) RetryAfter(now time.Time) time.Duration {
+	breaker.mutex.Lock()
+	defer breaker.mutex.Unlock()
+
+	if breaker.state != CircuitBreakerOpen {
+		return 0
+	}
+	reopensAt := breaker.openedAt.Add(breaker.openPeriod)
+	if !now.Before(reopensAt) {
+		return 0
+	}
+	return reopensAt.Sub(now)
+}
+
+// State returns the current state of the circuit.
+func (breaker *CircuitBreaker) State() string {
+	breaker.mutex.Lock()
+	defer breaker.mutex.Unlock()
+	return breaker.state
+}
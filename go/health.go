@@ -0,0 +1,236 @@
+/*
+ * OIDC² - Identity Certification Token Endpoint
+ *
+ * Endpoint for OpenID Connect's Identity Certification Token endpoint.
+ *
+ * API version: 0.5.0
+ */
+package ict
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"runtime"
+	"runtime/debug"
+	"sync"
+	"time"
+)
+
+// Version of the endpoint, which can be set at build time with '-ldflags "-X ict/go.Version=1.0.0"'.
+var Version = "dev"
+
+// Version of the REST API.
+const apiVersion = "0.5.0"
+
+// Maximum duration of a single readiness check.
+const readinessCheckTimeout = 5 * time.Second
+
+// Readiness checks by dependency name.
+var appReadinessChecks = map[string]*ReadinessCheck{}
+
+// ReadinessCheck checks whether a dependency works and caches the result.
+type ReadinessCheck struct {
+	check     func(ctx context.Context) error
+	endpoint  string
+	ttl       time.Duration
+	mutex     sync.Mutex
+	result    HealthCheck
+	checkedAt time.Time
+}
+
+// NewReadinessCheck creates a readiness check whose result is cached for ttl.
+// If endpoint is not empty, the result includes the state of its circuit breaker.
+func NewReadinessCheck(check func(ctx context.Context) error, endpoint string, ttl time.Duration) *ReadinessCheck {
+	return &ReadinessCheck{
+		check:    check,
+		endpoint: endpoint,
+		ttl:      ttl,
+	}
+}
+
+// Result returns the cached result, or checks the dependency if the cached result is older than the TTL at time now.
+// The check is detached from the cancellation of ctx, since its result is shared with all probes until it expires.
+func (readinessCheck *ReadinessCheck) Result(ctx context.Context, now time.Time) HealthCheck {
+	readinessCheck.mutex.Lock()
+	defer readinessCheck.mutex.Unlock()
+
+	// Concurrent probes wait for a running check instead of repeating it
+	if readinessCheck.checkedAt.IsZero() || now.Sub(readinessCheck.checkedAt) >= readinessCheck.ttl {
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), readinessCheckTimeout)
+		defer cancel()
+		result := HealthCheck{
+			Status:    HealthStatusUp,
+			CheckedAt: now.Unix(),
+		}
+		if err := readinessCheck.check(ctx); err != nil {
+			result.Status = HealthStatusDown
+			result.Error = err.Error()
+		}
+		readinessCheck.result = result
+		readinessCheck.checkedAt = now
+	}
+
+	result := readinessCheck.result
+	if readinessCheck.endpoint != "" {
+		result.CircuitBreaker = CircuitBreakerStates()[readinessCheck.endpoint]
+	}
+	return result
+}
+
+// NewReadinessChecks creates the readiness checks of the signing key, the nonce store and the endpoints of the OpenID Provider.
+func NewReadinessChecks(config AppConfiguration) map[string]*ReadinessCheck {
+	ttl := time.Duration(config.ReadinessCacheTtl) * time.Second
+	checks := map[string]*ReadinessCheck{
+		"signing_key": NewReadinessCheck(checkSigningKey, "", ttl),
+		"nonce_store": NewReadinessCheck(checkNonceStore, "", ttl),
+		"userinfo": NewReadinessCheck(func(ctx context.Context) error {
+			return checkHttpEndpoint(ctx, config.UserinfoEndpoint, config.UserinfoHost)
+		}, config.UserinfoEndpoint, ttl),
+	}
+	if config.AccessTokenValidation == "jwt" {
+		checks["jwks"] = NewReadinessCheck(func(ctx context.Context) error {
+			return checkHttpEndpoint(ctx, config.JwksUri, "")
+		}, config.JwksUri, ttl)
+	} else {
+		checks["introspection"] = NewReadinessCheck(func(ctx context.Context) error {
+			return checkHttpEndpoint(ctx, config.TokenIntrospectionEndpoint, config.TokenIntrospectionHost)
+		}, config.TokenIntrospectionEndpoint, ttl)
+	}
+	return checks
+}
+
+// checkSigningKey ensures that a signing key is loaded.
+func checkSigningKey(_ context.Context) error {
+	if appKeyRing == nil {
+		return errors.New("signing keys not loaded")
+	}
+	if appKeyRing.ActiveKey().PrivateKey == nil {
+		return errors.New("no active signing key")
+	}
+	return nil
+}
+
+// checkNonceStore ensures that the nonce store answers.
+func checkNonceStore(ctx context.Context) error {
+	if appNonceStore == nil {
+		return errors.New("nonce store not loaded")
+	}
+	if pinger, ok := appNonceStore.(NonceStorePinger); ok {
+		return pinger.Ping(ctx)
+	}
+	return nil
+}
+
+// checkHttpEndpoint ensures that an endpoint of the OpenID Provider is reachable.
+// Since the request is not authenticated, any response except a server error counts as reachable.
+func checkHttpEndpoint(ctx context.Context, endpoint string, host string) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", endpoint, nil)
+	if err != nil {
+		return errors.New("failed to create request to '" + endpoint + "': " + err.Error())
+	}
+	if host != "" {
+		req.Host = host
+	}
+	res, err := appHttpClient.Do(req)
+	if err != nil {
+		return errors.New("failed to request '" + endpoint + "': " + err.Error())
+	}
+	defer res.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(res.Body, 64*1024))
+	if res.StatusCode >= 500 {
+		return errors.New("endpoint '" + endpoint + "' responded with status code " + fmt.Sprint(res.StatusCode))
+	}
+	return nil
+}
+
+// writeHealthResponse writes an uncacheable JSON response.
+func writeHealthResponse(w http.ResponseWriter, statusCode int, response interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(response)
+}
+
+// GetHealthz responds whether the endpoint is alive, without checking its dependencies.
+func GetHealthz(w http.ResponseWriter, r *http.Request) {
+	writeHealthResponse(w, http.StatusOK, HealthResponse{Status: HealthStatusUp})
+}
+
+// checkReadiness checks all dependencies concurrently, and returns whether the endpoint and all its dependencies work.
+func checkReadiness(ctx context.Context, now time.Time) HealthResponse {
+	response := HealthResponse{
+		Status: HealthStatusUp,
+		Checks: make(map[string]HealthCheck, len(appReadinessChecks)),
+	}
+
+	var mutex sync.Mutex
+	var wg sync.WaitGroup
+	for name, readinessCheck := range appReadinessChecks {
+		wg.Add(1)
+		go func(name string, readinessCheck *ReadinessCheck) {
+			defer wg.Done()
+			result := readinessCheck.Result(ctx, now)
+			mutex.Lock()
+			defer mutex.Unlock()
+			response.Checks[name] = result
+			if result.Status != HealthStatusUp {
+				response.Status = HealthStatusDown
+			}
+		}(name, readinessCheck)
+	}
+	wg.Wait()
+	return response
+}
+
+// writeReadinessResponse writes the readiness with status code 503 if a dependency is down.
+func writeReadinessResponse(w http.ResponseWriter, response HealthResponse) {
+	statusCode := http.StatusOK
+	if response.Status != HealthStatusUp {
+		statusCode = http.StatusServiceUnavailable
+	}
+	writeHealthResponse(w, statusCode, response)
+}
+
+// GetReadyz responds whether the endpoint and all its dependencies work.
+// It is served on the port if an admin port is configured, so the status of each dependency is omitted,
+// since errors may disclose internal URLs.
+// Results are cached for the configured readiness cache TTL.
+func GetReadyz(w http.ResponseWriter, r *http.Request) {
+	response := checkReadiness(r.Context(), time.Now())
+	writeReadinessResponse(w, HealthResponse{Status: response.Status})
+}
+
+// GetAdminReadyz responds whether the endpoint and all its dependencies work, including the status of each dependency.
+// It is served on the admin port, or on the port if no admin port is configured.
+func GetAdminReadyz(w http.ResponseWriter, r *http.Request) {
+	writeReadinessResponse(w, checkReadiness(r.Context(), time.Now()))
+}
+
+// GetVersion responds with the version of the endpoint and the build information.
+func GetVersion(w http.ResponseWriter, r *http.Request) {
+	response := VersionResponse{
+		Version:    Version,
+		ApiVersion: apiVersion,
+		GoVersion:  runtime.Version(),
+	}
+	if info, ok := debug.ReadBuildInfo(); ok {
+		if response.Version == "dev" && info.Main.Version != "" && info.Main.Version != "(devel)" {
+			response.Version = info.Main.Version
+		}
+		for _, setting := range info.Settings {
+			switch setting.Key {
+			case "vcs.revision":
+				response.Revision = setting.Value
+			case "vcs.time":
+				response.RevisionTime = setting.Value
+			case "vcs.modified":
+				response.Modified = setting.Value == "true"
+			}
+		}
+	}
+	writeHealthResponse(w, http.StatusOK, response)
+}
@@ -0,0 +1,84 @@
+/*
+ * OIDC² - Identity Certification Token Endpoint
+ *
+ * Endpoint for OpenID Connect's Identity Certification Token endpoint.
+ *
+ * API version: 0.5.0
+ */
+package ict
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+)
+
+// HTTP client for all requests to the OpenID Provider.
+var appHttpClient = http.DefaultClient
+
+// NewHttpClient creates the HTTP client for requests to the OpenID Provider
+// with the configured timeouts, connection pool, trusted CAs, client certificate and proxy.
+func NewHttpClient(config AppConfiguration) (*http.Client, error) {
+	connectTimeout := time.Duration(config.HttpConnectTimeout) * time.Second
+	dialer := &net.Dialer{
+		Timeout:   connectTimeout,
+		KeepAlive: 30 * time.Second,
+	}
+
+	// Load TLS configuration
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	if config.HttpCaFile != "" {
+		caData, err := os.ReadFile(config.HttpCaFile)
+		if err != nil {
+			return nil, errors.New("failed to read CA file: " + err.Error())
+		}
+		caPool := x509.NewCertPool()
+		if !caPool.AppendCertsFromPEM(caData) {
+			return nil, errors.New("failed to parse CA file '" + config.HttpCaFile + "': no PEM encoded certificate found")
+		}
+		tlsConfig.RootCAs = caPool
+	}
+	if config.HttpClientCertFile != "" || config.HttpClientKeyFile != "" {
+		if config.HttpClientCertFile == "" || config.HttpClientKeyFile == "" {
+			return nil, errors.New("failed to load client certificate: both certificate and key file are required")
+		}
+		certificate, err := tls.LoadX509KeyPair(config.HttpClientCertFile, config.HttpClientKeyFile)
+		if err != nil {
+			return nil, errors.New("failed to load client certificate: " + err.Error())
+		}
+		tlsConfig.Certificates = []tls.Certificate{certificate}
+	}
+
+	// Load proxy configuration
+	proxy := http.ProxyFromEnvironment
+	if config.HttpProxyUrl != "" {
+		proxyUrl, err := url.Parse(config.HttpProxyUrl)
+		if err != nil {
+			return nil, errors.New("failed to parse proxy URL: " + err.Error())
+		}
+		proxy = http.ProxyURL(proxyUrl)
+	}
+
+	transport := &http.Transport{
+		Proxy:                 proxy,
+		DialContext:           dialer.DialContext,
+		TLSClientConfig:       tlsConfig,
+		TLSHandshakeTimeout:   connectTimeout,
+		MaxIdleConns:          int(config.HttpMaxIdleConnections),
+		MaxIdleConnsPerHost:   int(config.HttpMaxIdleConnections),
+		ResponseHeaderTimeout: time.Duration(config.HttpReadTimeout) * time.Second,
+		IdleConnTimeout:       90 * time.Second,
+		ExpectContinueTimeout: time.Second,
+		ForceAttemptHTTP2:     true,
+	}
+
+	// The whole request is limited by the request deadline of the incoming request
+	return &http.Client{
+		Transport: &tracingTransport{base: transport},
+	}, nil
+}
@@ -0,0 +1,99 @@
+/*
+ * OIDC² - Identity Certification Token Endpoint
+ *
+ * Endpoint for OpenID Connect's Identity Certification Token endpoint.
+ *
+ * API version: 0.5.0
+ */
+package ict
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// newHangingServer starts a server which does not respond until the test ends or the request is cancelled.
+func newHangingServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	t.Cleanup(func() {
+		close(release)
+		server.Close()
+	})
+	return server
+}
+
+// useHttpClient replaces the HTTP client and disables retries for the duration of the test.
+func useHttpClient(t *testing.T, client *http.Client) {
+	t.Helper()
+	previousClient, previousPolicy := appHttpClient, appUpstreamPolicy
+	appHttpClient = client
+	appUpstreamPolicy.Retries = 0
+	t.Cleanup(func() {
+		appHttpClient, appUpstreamPolicy = previousClient, previousPolicy
+	})
+}
+
+func TestHttpClientTimeoutAbortsHangingUserinfoRequest(t *testing.T) {
+	server := newHangingServer(t)
+	client, err := NewHttpClient(AppConfiguration{
+		HttpConnectTimeout:     1,
+		HttpReadTimeout:        1,
+		HttpMaxIdleConnections: 1,
+	})
+	if err != nil {
+		t.Fatalf("failed to create HTTP client: %v", err)
+	}
+	useHttpClient(t, client)
+
+	start := time.Now()
+	_, err, authFailed := RequestUserinfo(context.Background(), "access-token", server.URL, "")
+	elapsed := time.Since(start)
+	if err == nil || authFailed {
+		t.Fatalf("RequestUserinfo() = %v, auth failed %v, want timeout error", err, authFailed)
+	}
+	if elapsed > 3*time.Second {
+		t.Errorf("RequestUserinfo() returned after %v, want about the client timeout of 1s", elapsed)
+	}
+}
+
+func TestRequestDeadlineAbortsHangingIntrospectionRequest(t *testing.T) {
+	server := newHangingServer(t)
+
+	// The client timeout is longer than the request deadline
+	useHttpClient(t, &http.Client{Timeout: time.Minute})
+	previousConfig := appConfig
+	appConfig.RequestTimeout = 1
+	t.Cleanup(func() { appConfig = previousConfig })
+
+	var introspectionErr error
+	handler := RequestDeadline(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, introspectionErr, _ = IntrospectAccessToken(r.Context(), "access-token", server.URL, time.Now())
+		if !LogAndSendContextError(w, r) {
+			w.WriteHeader(http.StatusOK)
+		}
+	}))
+
+	start := time.Now()
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("POST", "/", nil))
+	elapsed := time.Since(start)
+	if introspectionErr == nil {
+		t.Fatalf("IntrospectAccessToken() = nil, want deadline error")
+	}
+	if w.Code != http.StatusGatewayTimeout {
+		t.Errorf("status code = %d, want %d", w.Code, http.StatusGatewayTimeout)
+	}
+	if elapsed > 3*time.Second {
+		t.Errorf("request returned after %v, want about the request deadline of 1s", elapsed)
+	}
+}
@@ -0,0 +1,121 @@
+/*
+ * OIDC² - Identity Certification Token Endpoint
+ *
+ * Endpoint for OpenID Connect's Identity Certification Token endpoint.
+ *
+ * API version: 0.5.0
+ */
+package ict
+
+import (
+	"crypto"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// PEM type of private signing keys encrypted with the key encryption key.
+const encryptedSigningKeyPemType = "ENCRYPTED SIGNING KEY"
+
+// Length of the key encryption key in bytes, which selects AES-256.
+const keyEncryptionKeyLength = 32
+
+// ReadKeyEncryptionKey reads the base64 encoded 256 bit key encryption key from fileName,
+// e.g., generated with 'openssl rand -base64 32'.
+func ReadKeyEncryptionKey(fileName string) ([]byte, error) {
+	return readSecretKeyFile(fileName, "key encryption key")
+}
+
+// readSecretKeyFile reads a base64 encoded 256 bit key from fileName. The name of the key is used in errors.
+func readSecretKeyFile(fileName string, name string) ([]byte, error) {
+	data, err := os.ReadFile(fileName)
+	if err != nil {
+		return nil, errors.New("failed to read " + name + " file: " + err.Error())
+	}
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(data)))
+	if err != nil {
+		return nil, errors.New("failed to decode " + name + ": " + err.Error())
+	}
+	if len(key) != keyEncryptionKeyLength {
+		return nil, errors.New(name + " must be " + strconv.Itoa(keyEncryptionKeyLength) + " bytes but is " + strconv.Itoa(len(key)) + " bytes")
+	}
+	return key, nil
+}
+
+// EncryptSigningKey encrypts the private key of a signing key with AES-256-GCM and encodes it as PEM.
+// The key ID is authenticated, so an encrypted key cannot be swapped with the key of another row.
+func EncryptSigningKey(kek []byte, keyId string, privateKey crypto.Signer) (string, error) {
+	privateKeyDer, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	if err != nil {
+		return "", errors.New("failed to encode signing key '" + keyId + "': " + err.Error())
+	}
+	aead, err := newKeyEncryptionCipher(kek)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", errors.New("failed to generate nonce: " + err.Error())
+	}
+	ciphertext := aead.Seal(nonce, nonce, privateKeyDer, []byte(keyId))
+	return string(pem.EncodeToMemory(&pem.Block{Type: encryptedSigningKeyPemType, Bytes: ciphertext})), nil
+}
+
+// IsEncryptedSigningKey returns whether privateKeyPem was encrypted by EncryptSigningKey.
+func IsEncryptedSigningKey(privateKeyPem string) bool {
+	block, _ := pem.Decode([]byte(privateKeyPem))
+	return block != nil && block.Type == encryptedSigningKeyPemType
+}
+
+// DecryptSigningKey decrypts a private key encrypted by EncryptSigningKey.
+// Unencrypted PEM encoded keys, which were persisted by previous versions, are returned as they are.
+func DecryptSigningKey(kek []byte, keyId string, privateKeyPem string) (crypto.Signer, error) {
+	if !IsEncryptedSigningKey(privateKeyPem) {
+		return PrivateKeyFromPem([]byte(privateKeyPem))
+	}
+	block, _ := pem.Decode([]byte(privateKeyPem))
+
+	aead, err := newKeyEncryptionCipher(kek)
+	if err != nil {
+		return nil, err
+	}
+	if len(block.Bytes) < aead.NonceSize() {
+		return nil, errors.New("encrypted private key is too short")
+	}
+	nonce, ciphertext := block.Bytes[:aead.NonceSize()], block.Bytes[aead.NonceSize():]
+	privateKeyDer, err := aead.Open(nil, nonce, ciphertext, []byte(keyId))
+	if err != nil {
+		return nil, errors.New("failed to decrypt private key, the key encryption key is wrong or the key was modified")
+	}
+	privateKey, err := x509.ParsePKCS8PrivateKey(privateKeyDer)
+	if err != nil {
+		return nil, errors.New("failed to parse decrypted private key: " + err.Error())
+	}
+	signer, ok := privateKey.(crypto.Signer)
+	if !ok {
+		return nil, errors.New("decrypted private key cannot sign")
+	}
+	return signer, nil
+}
+
+func newKeyEncryptionCipher(kek []byte) (cipher.AEAD, error) {
+	if len(kek) != keyEncryptionKeyLength {
+		return nil, errors.New("key encryption key not configured")
+	}
+	block, err := aes.NewCipher(kek)
+	if err != nil {
+		return nil, errors.New("failed to create key encryption cipher: " + err.Error())
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, errors.New("failed to create key encryption cipher: " + err.Error())
+	}
+	return aead, nil
+}
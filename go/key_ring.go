@@ -0,0 +1,477 @@
+/*
+ * OIDC² - Identity Certification Token Endpoint
+ *
+ * Endpoint for OpenID Connect's Identity Certification Token endpoint.
+ *
+ * API version: 0.5.0
+ */
+package ict
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"os"
+	"os/signal"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+var appKeyRing *KeyRing
+
+// KeyRing holds the signing keys of the endpoint.
+// The active key signs Identity Certification Tokens, while next, active and retiring keys are published.
+type KeyRing struct {
+	mutex sync.RWMutex
+	// Database to persist the keys in. Nil if rotation is disabled.
+	db *sql.DB
+	// Signing algorithm of newly generated keys.
+	algorithm jwt.SigningMethod
+	// Period after which the active key is replaced by the next key.
+	rotationPeriod time.Duration
+	// Period a retiring key stays published.
+	retirementPeriod time.Duration
+	// Key to encrypt the persisted private keys with.
+	kek []byte
+	// Whether a rotation was requested, which is postponed until the next key is known to all verifiers.
+	rotationRequested bool
+	// Whether the keys differ from the persisted keys, e.g., because unencrypted keys were loaded.
+	unpersisted bool
+	keys        []SigningKey
+	jwks        JwkSet
+}
+
+// NewStaticKeyRing creates a key ring which only holds the provided key and never rotates.
+func NewStaticKeyRing(key SigningKey) (*KeyRing, error) {
+	key.State = ACTIVE
+	ring := &KeyRing{
+		algorithm: key.Algorithm,
+		keys:      []SigningKey{key},
+	}
+	if err := ring.update(); err != nil {
+		return nil, err
+	}
+	return ring, nil
+}
+
+// LoadKeyRing loads the key ring persisted in db, whose private keys are encrypted with kek.
+// If db does not contain an active key yet, initialKey becomes the active key.
+// Unencrypted keys of previous versions are encrypted when the key ring is persisted.
+func LoadKeyRing(db *sql.DB, kek []byte, initialKey SigningKey, algorithm jwt.SigningMethod, rotationPeriod time.Duration, retirementPeriod time.Duration, now time.Time) (*KeyRing, error) {
+	ring := &KeyRing{
+		db:               db,
+		algorithm:        algorithm,
+		rotationPeriod:   rotationPeriod,
+		retirementPeriod: retirementPeriod,
+		kek:              kek,
+	}
+
+	// Read persisted keys
+	rows, err := db.Query("SELECT kid, alg, state, private_key, published, activated, not_after FROM signing_keys")
+	if err != nil {
+		return nil, errors.New("failed to read signing keys: " + err.Error())
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var keyId, alg, state, privateKeyPem string
+		var published, activated, notAfter sql.NullTime
+		if err := rows.Scan(&keyId, &alg, &state, &privateKeyPem, &published, &activated, &notAfter); err != nil {
+			return nil, errors.New("failed to read signing keys: " + err.Error())
+		}
+		key, err := signingKeyFromRow(kek, keyId, alg, state, privateKeyPem, published, activated, notAfter)
+		if err != nil {
+			return nil, errors.New("failed to read signing key '" + keyId + "': " + err.Error())
+		}
+		if !IsEncryptedSigningKey(privateKeyPem) {
+			ring.unpersisted = true
+		}
+		ring.keys = append(ring.keys, key)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.New("failed to read signing keys: " + err.Error())
+	}
+
+	// Seed key ring with the initial key
+	if _, ok := ring.activeKey(); !ok {
+		slog.Info("no active signing key found in database, activating key", "kid", initialKey.KeyId)
+		initialKey.State = ACTIVE
+		initialKey.Activated = now
+		initialKey.NotAfter = time.Time{}
+		ring.keys = append(ring.keys, initialKey)
+		ring.unpersisted = true
+	}
+
+	// Ensure that a next key is published ahead of rotation
+	if err := ring.Maintain(now); err != nil {
+		return nil, err
+	}
+	return ring, nil
+}
+
+func signingKeyFromRow(kek []byte, keyId string, alg string, state string, privateKeyPem string, published sql.NullTime, activated sql.NullTime, notAfter sql.NullTime) (SigningKey, error) {
+	algorithm, ok := SigningAlgorithmFromJwa(alg)
+	if !ok {
+		return SigningKey{}, errors.New("signing algorithm '" + alg + "' not supported")
+	}
+	keyState, ok := SigningKeyStateFromString(state)
+	if !ok {
+		return SigningKey{}, errors.New("key state '" + state + "' not supported")
+	}
+	privateKey, err := DecryptSigningKey(kek, keyId, privateKeyPem)
+	if err != nil {
+		return SigningKey{}, err
+	}
+	if err = ValidatePrivateKeyAlgorithm(privateKey, algorithm); err != nil {
+		return SigningKey{}, err
+	}
+	return SigningKey{
+		KeyId:      keyId,
+		Algorithm:  algorithm,
+		PrivateKey: privateKey,
+		State:      keyState,
+		Published:  published.Time,
+		Activated:  activated.Time,
+		NotAfter:   notAfter.Time,
+	}, nil
+}
+
+// ActiveKey returns the key to sign Identity Certification Tokens with.
+func (ring *KeyRing) ActiveKey() SigningKey {
+	ring.mutex.RLock()
+	defer ring.mutex.RUnlock()
+	key, _ := ring.activeKey()
+	return key
+}
+
+// Jwks returns the JWK set of all published keys.
+func (ring *KeyRing) Jwks() JwkSet {
+	ring.mutex.RLock()
+	defer ring.mutex.RUnlock()
+	return ring.jwks
+}
+
+// Algorithms returns the distinct signing algorithms of all published keys.
+func (ring *KeyRing) Algorithms() []string {
+	ring.mutex.RLock()
+	defer ring.mutex.RUnlock()
+	algorithms := []string{}
+	for _, key := range ring.keys {
+		if !containsString(algorithms, key.Algorithm.Alg()) {
+			algorithms = append(algorithms, key.Algorithm.Alg())
+		}
+	}
+	return algorithms
+}
+
+// PublicKey returns the public key of the published key with ID keyId.
+// It implements verify.PublicKeyResolver.
+func (ring *KeyRing) PublicKey(keyId string, alg jwt.SigningMethod) (crypto.PublicKey, error) {
+	ring.mutex.RLock()
+	defer ring.mutex.RUnlock()
+	for _, key := range ring.keys {
+		if key.KeyId != keyId {
+			continue
+		}
+		if key.Algorithm != alg {
+			return nil, errors.New("key '" + keyId + "' is not used with signing algorithm '" + alg.Alg() + "'")
+		}
+		return key.PrivateKey.Public(), nil
+	}
+	return nil, errors.New("key '" + keyId + "' not found")
+}
+
+// Rotate retires the active key, activates the next key and generates a new next key.
+// If the next key is published for less than the JWK set cache lifetime, the rotation is postponed until it is,
+// since verifiers which cached the JWK set would reject tokens signed with it.
+func (ring *KeyRing) Rotate(now time.Time) error {
+	if ring.db == nil {
+		return errors.New("key rotation is disabled")
+	}
+	ring.mutex.Lock()
+	ring.rotationRequested = true
+	ring.mutex.Unlock()
+	if err := ring.Maintain(now); err != nil {
+		return err
+	}
+
+	ring.mutex.RLock()
+	defer ring.mutex.RUnlock()
+	if ring.rotationRequested {
+		for _, key := range ring.keys {
+			if key.State == NEXT {
+				slog.Info("postponed signing key rotation until the next key is cached by verifiers", "kid", key.KeyId, "activates_after", key.Published.Add(jwksCacheLifetime))
+			}
+		}
+	}
+	return nil
+}
+
+// Maintain rotates the keys if the rotation period of the active key elapsed and removes expired retiring keys.
+// New keys are generated without holding the lock, since generating RSA keys takes long and would block signing.
+func (ring *KeyRing) Maintain(now time.Time) error {
+	if ring.db == nil {
+		return nil
+	}
+	for {
+		ring.mutex.RLock()
+		needed := ring.plan(now).needed
+		ring.mutex.RUnlock()
+
+		generated := make([]SigningKey, 0, needed)
+		for len(generated) < needed {
+			key, err := GenerateSigningKey(ring.algorithm)
+			if err != nil {
+				return err
+			}
+			generated = append(generated, key)
+		}
+
+		// Another maintenance may have changed the keys in the meantime, which requires more keys
+		ring.mutex.Lock()
+		if ring.plan(now).needed <= len(generated) {
+			err := ring.maintain(now, generated)
+			ring.mutex.Unlock()
+			return err
+		}
+		ring.mutex.Unlock()
+	}
+}
+
+// RunRotation maintains the key ring every interval and rotates the keys on SIGHUP until stop is closed.
+func (ring *KeyRing) RunRotation(interval time.Duration, stop <-chan struct{}) {
+	if ring.db == nil {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+	defer signal.Stop(hangup)
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-hangup:
+			slog.Info("received SIGHUP, rotating signing keys")
+			if err := ring.Rotate(time.Now()); err != nil {
+				slog.Error("failed to rotate signing keys", "error", err)
+			}
+		case <-ticker.C:
+			if err := ring.Maintain(time.Now()); err != nil {
+				slog.Error("failed to maintain signing keys", "error", err)
+			}
+		}
+	}
+}
+
+func (ring *KeyRing) activeKey() (SigningKey, bool) {
+	for _, key := range ring.keys {
+		if key.State == ACTIVE {
+			return key, true
+		}
+	}
+	return SigningKey{}, false
+}
+
+// keyRingPlan describes the changes of a maintenance of the key ring.
+type keyRingPlan struct {
+	active *SigningKey
+	next   *SigningKey
+	// Keys which are kept unchanged, except for expired retiring keys.
+	kept []SigningKey
+	// Whether a retiring key expired.
+	expired bool
+	// Whether the next key becomes the active key.
+	rotate bool
+	// Number of keys to generate.
+	needed int
+}
+
+// plan determines the changes of a maintenance at time now.
+func (ring *KeyRing) plan(now time.Time) keyRingPlan {
+	plan := keyRingPlan{kept: make([]SigningKey, 0, len(ring.keys)+1)}
+	for i := range ring.keys {
+		key := ring.keys[i]
+		switch key.State {
+		case RETIRING:
+			// Drop retiring keys once all tokens signed with them are expired
+			if !key.NotAfter.After(now) {
+				plan.expired = true
+				continue
+			}
+		case ACTIVE:
+			plan.active = &key
+			continue
+		case NEXT:
+			plan.next = &key
+			continue
+		}
+		plan.kept = append(plan.kept, key)
+	}
+
+	// Rotate keys once the next key is published for the JWK set cache lifetime, unless no key is active
+	rotationDue := ring.rotationRequested || (plan.active != nil && ring.rotationPeriod > 0 && !plan.active.Activated.Add(ring.rotationPeriod).After(now))
+	nextKeyCached := plan.next != nil && !plan.next.Published.Add(jwksCacheLifetime).After(now)
+	plan.rotate = plan.active == nil || (rotationDue && nextKeyCached)
+
+	// A rotation consumes the next key, which must then be replaced
+	if plan.next == nil {
+		plan.needed++
+	}
+	if plan.rotate && (plan.next != nil || plan.active == nil) {
+		plan.needed++
+	}
+	return plan
+}
+
+// maintain applies the plan at time now with the keys generated for it, and persists the keys if they changed.
+func (ring *KeyRing) maintain(now time.Time, generated []SigningKey) error {
+	plan := ring.plan(now)
+	if len(generated) < plan.needed {
+		return errors.New("failed to maintain signing keys: " + strconv.Itoa(plan.needed) + " new keys required")
+	}
+	keys := plan.kept
+	active, next := plan.active, plan.next
+	for _, key := range ring.keys {
+		if key.State == RETIRING && !key.NotAfter.After(now) {
+			slog.Info("signing key expired", "kid", key.KeyId)
+		}
+	}
+
+	if plan.rotate {
+		if active != nil {
+			slog.Info("retiring signing key", "kid", active.KeyId)
+			active.State = RETIRING
+			active.NotAfter = now.Add(ring.retirementPeriod)
+			keys = append(keys, *active)
+		}
+		if next == nil {
+			next, generated = &generated[0], generated[1:]
+			next.Published = now
+		}
+		slog.Info("activating signing key", "kid", next.KeyId)
+		next.State = ACTIVE
+		next.Activated = now
+		active = next
+		next = nil
+		ring.rotationRequested = false
+	}
+	keys = append(keys, *active)
+
+	// Publish next key ahead of rotation
+	if next == nil {
+		next = &generated[0]
+		slog.Info("generated next signing key", "kid", next.KeyId)
+		next.Published = now
+	}
+	keys = append(keys, *next)
+
+	// Persist and apply changes, unless the keys are unchanged
+	if !plan.expired && !plan.rotate && plan.needed == 0 && !ring.unpersisted {
+		return nil
+	}
+	if err := persistSigningKeys(ring.db, ring.kek, keys); err != nil {
+		return err
+	}
+	ring.unpersisted = false
+	ring.keys = keys
+	return ring.update()
+}
+
+func (ring *KeyRing) update() error {
+	jwks, err := JwkSetFromSigningKeys(ring.keys)
+	if err != nil {
+		return err
+	}
+	ring.jwks = jwks
+	return nil
+}
+
+func persistSigningKeys(db *sql.DB, kek []byte, keys []SigningKey) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return errors.New("failed to persist signing keys: " + err.Error())
+	}
+	defer tx.Rollback()
+
+	if _, err = tx.Exec("DELETE FROM signing_keys"); err != nil {
+		return errors.New("failed to persist signing keys: " + err.Error())
+	}
+	for _, key := range keys {
+		privateKeyPem, err := EncryptSigningKey(kek, key.KeyId, key.PrivateKey)
+		if err != nil {
+			return errors.New("failed to encrypt signing key '" + key.KeyId + "': " + err.Error())
+		}
+		published := sql.NullTime{Time: key.Published, Valid: !key.Published.IsZero()}
+		activated := sql.NullTime{Time: key.Activated, Valid: !key.Activated.IsZero()}
+		notAfter := sql.NullTime{Time: key.NotAfter, Valid: !key.NotAfter.IsZero()}
+		_, err = tx.Exec(
+			"INSERT INTO signing_keys (kid, alg, state, private_key, published, activated, not_after) VALUES (?, ?, ?, ?, ?, ?, ?)",
+			key.KeyId, key.Algorithm.Alg(), string(key.State), privateKeyPem, published, activated, notAfter,
+		)
+		if err != nil {
+			return errors.New("failed to persist signing key '" + key.KeyId + "': " + err.Error())
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return errors.New("failed to persist signing keys: " + err.Error())
+	}
+	return nil
+}
+
+// GenerateSigningKey generates a new private key for signing algorithm alg.
+// The key ID is the key's JWK thumbprint.
+func GenerateSigningKey(alg jwt.SigningMethod) (SigningKey, error) {
+	var privateKey crypto.Signer
+	var err error
+	switch alg {
+	case jwt.SigningMethodES256:
+		privateKey, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case jwt.SigningMethodES384:
+		privateKey, err = ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	case jwt.SigningMethodES512:
+		privateKey, err = ecdsa.GenerateKey(elliptic.P521(), rand.Reader)
+	case jwt.SigningMethodRS256, jwt.SigningMethodRS384, jwt.SigningMethodRS512:
+		privateKey, err = rsa.GenerateKey(rand.Reader, 3072)
+	case jwt.SigningMethodEdDSA:
+		_, privateKey, err = ed25519.GenerateKey(rand.Reader)
+	default:
+		return SigningKey{}, errors.New("failed to generate signing key: signing algorithm '" + alg.Alg() + "' not supported")
+	}
+	if err != nil {
+		return SigningKey{}, errors.New("failed to generate signing key: " + err.Error())
+	}
+
+	keyId, err := JwkThumbprint(privateKey.Public())
+	if err != nil {
+		return SigningKey{}, errors.New("failed to compute key ID: " + err.Error())
+	}
+
+	return SigningKey{
+		KeyId:      keyId,
+		Algorithm:  alg,
+		PrivateKey: privateKey,
+		State:      NEXT,
+	}, nil
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
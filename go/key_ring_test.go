@@ -0,0 +1,222 @@
+/*
+ * OIDC² - Identity Certification Token Endpoint
+ *
+ * Endpoint for OpenID Connect's Identity Certification Token endpoint.
+ *
+ * API version: 0.5.0
+ */
+package ict
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/x509"
+	"database/sql"
+	"encoding/pem"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+const (
+	testRotationPeriod   = time.Hour
+	testRetirementPeriod = 2 * time.Hour
+)
+
+func newTestKeyEncryptionKey(t *testing.T) []byte {
+	t.Helper()
+	kek := make([]byte, keyEncryptionKeyLength)
+	if _, err := rand.Read(kek); err != nil {
+		t.Fatalf("failed to generate key encryption key: %v", err)
+	}
+	return kek
+}
+
+func newTestDatabase(t *testing.T) *sql.DB {
+	t.Helper()
+	db, err := loadDatabase(filepath.Join(t.TempDir(), "ict.db"))
+	if err != nil {
+		t.Fatalf("failed to load database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+// keyIdsByState returns the IDs of the keys of ring by state.
+func keyIdsByState(ring *KeyRing) map[SigningKeyState][]string {
+	ring.mutex.RLock()
+	defer ring.mutex.RUnlock()
+	keyIds := map[SigningKeyState][]string{}
+	for _, key := range ring.keys {
+		keyIds[key.State] = append(keyIds[key.State], key.KeyId)
+	}
+	return keyIds
+}
+
+func TestKeyRingRotationWaitsForCachedNextKey(t *testing.T) {
+	db := newTestDatabase(t)
+	initialKey := newTestSigningKey(t)
+	start := time.Unix(1700000000, 0)
+	ring, err := LoadKeyRing(db, newTestKeyEncryptionKey(t), initialKey, jwt.SigningMethodES256, testRotationPeriod, testRetirementPeriod, start)
+	if err != nil {
+		t.Fatalf("LoadKeyRing() = %v, want nil", err)
+	}
+	keyIds := keyIdsByState(ring)
+	if ring.ActiveKey().KeyId != initialKey.KeyId || len(keyIds[NEXT]) != 1 {
+		t.Fatalf("keys after loading = %v, want active initial key and a next key", keyIds)
+	}
+	nextKeyId := keyIds[NEXT][0]
+
+	// A requested rotation is postponed until the next key is published for the JWK set cache lifetime
+	if err := ring.Rotate(start.Add(jwksCacheLifetime - time.Second)); err != nil {
+		t.Fatalf("Rotate() = %v, want nil", err)
+	}
+	if ring.ActiveKey().KeyId != initialKey.KeyId {
+		t.Fatalf("active key rotated before the next key is cached by verifiers")
+	}
+	rotated := start.Add(jwksCacheLifetime)
+	if err := ring.Maintain(rotated); err != nil {
+		t.Fatalf("Maintain() = %v, want nil", err)
+	}
+	keyIds = keyIdsByState(ring)
+	if ring.ActiveKey().KeyId != nextKeyId || len(keyIds[RETIRING]) != 1 || keyIds[RETIRING][0] != initialKey.KeyId || len(keyIds[NEXT]) != 1 {
+		t.Fatalf("keys after postponed rotation = %v, want active key '%s', retiring key '%s' and a new next key", keyIds, nextKeyId, initialKey.KeyId)
+	}
+
+	// The rotation period starts when the key is activated
+	if err := ring.Maintain(rotated.Add(testRotationPeriod - time.Second)); err != nil {
+		t.Fatalf("Maintain() = %v, want nil", err)
+	}
+	if ring.ActiveKey().KeyId != nextKeyId {
+		t.Fatalf("active key rotated before the rotation period elapsed")
+	}
+	if err := ring.Maintain(rotated.Add(testRotationPeriod)); err != nil {
+		t.Fatalf("Maintain() = %v, want nil", err)
+	}
+	if ring.ActiveKey().KeyId == nextKeyId {
+		t.Fatalf("active key not rotated after the rotation period elapsed")
+	}
+}
+
+func TestKeyRingPublishesRetiringKeyForRetirementPeriod(t *testing.T) {
+	db := newTestDatabase(t)
+	initialKey := newTestSigningKey(t)
+	start := time.Unix(1700000000, 0)
+	ring, err := LoadKeyRing(db, newTestKeyEncryptionKey(t), initialKey, jwt.SigningMethodES256, testRotationPeriod, testRetirementPeriod, start)
+	if err != nil {
+		t.Fatalf("LoadKeyRing() = %v, want nil", err)
+	}
+	retired := start.Add(testRotationPeriod)
+	if err := ring.Maintain(retired); err != nil {
+		t.Fatalf("Maintain() = %v, want nil", err)
+	}
+
+	// Tokens signed with the retiring key stay verifiable until the retirement period elapsed
+	if _, err := ring.PublicKey(initialKey.KeyId, jwt.SigningMethodES256); err != nil {
+		t.Fatalf("PublicKey() of retiring key = %v, want nil", err)
+	}
+	if err := ring.Maintain(retired.Add(testRetirementPeriod - time.Second)); err != nil {
+		t.Fatalf("Maintain() = %v, want nil", err)
+	}
+	if _, err := ring.PublicKey(initialKey.KeyId, jwt.SigningMethodES256); err != nil {
+		t.Fatalf("PublicKey() of retiring key before the retirement period elapsed = %v, want nil", err)
+	}
+	if err := ring.Maintain(retired.Add(testRetirementPeriod)); err != nil {
+		t.Fatalf("Maintain() = %v, want nil", err)
+	}
+	if _, err := ring.PublicKey(initialKey.KeyId, jwt.SigningMethodES256); err == nil {
+		t.Fatalf("PublicKey() of expired retiring key = nil, want error")
+	}
+}
+
+func TestKeyRingPersistsEncryptedKeysOnlyIfChanged(t *testing.T) {
+	db := newTestDatabase(t)
+	kek := newTestKeyEncryptionKey(t)
+	initialKey := newTestSigningKey(t)
+	start := time.Unix(1700000000, 0)
+	ring, err := LoadKeyRing(db, kek, initialKey, jwt.SigningMethodES256, testRotationPeriod, testRetirementPeriod, start)
+	if err != nil {
+		t.Fatalf("LoadKeyRing() = %v, want nil", err)
+	}
+
+	// Private keys are persisted encrypted
+	rows, err := db.Query("SELECT private_key FROM signing_keys")
+	if err != nil {
+		t.Fatalf("failed to read signing keys: %v", err)
+	}
+	for rows.Next() {
+		var privateKeyPem string
+		rows.Scan(&privateKeyPem)
+		if !IsEncryptedSigningKey(privateKeyPem) {
+			t.Errorf("signing key persisted unencrypted")
+		}
+	}
+	rows.Close()
+
+	// Maintenance without changes does not write the keys
+	if _, err := db.Exec("UPDATE signing_keys SET activated = ? WHERE kid = ?", start.Add(time.Second), initialKey.KeyId); err != nil {
+		t.Fatalf("failed to mark signing key: %v", err)
+	}
+	if err := ring.Maintain(start.Add(time.Minute)); err != nil {
+		t.Fatalf("Maintain() = %v, want nil", err)
+	}
+	var activated time.Time
+	if err := db.QueryRow("SELECT activated FROM signing_keys WHERE kid = ?", initialKey.KeyId).Scan(&activated); err != nil {
+		t.Fatalf("failed to read signing key: %v", err)
+	}
+	if !activated.Equal(start.Add(time.Second)) {
+		t.Errorf("unchanged signing keys were persisted again")
+	}
+
+	// The persisted keys are loaded with the key encryption key
+	reloaded, err := LoadKeyRing(db, kek, newTestSigningKey(t), jwt.SigningMethodES256, testRotationPeriod, testRetirementPeriod, start.Add(time.Minute))
+	if err != nil {
+		t.Fatalf("LoadKeyRing() of persisted keys = %v, want nil", err)
+	}
+	if reloaded.ActiveKey().KeyId != initialKey.KeyId {
+		t.Errorf("active key after reloading = '%s', want '%s'", reloaded.ActiveKey().KeyId, initialKey.KeyId)
+	}
+	if _, err := LoadKeyRing(db, newTestKeyEncryptionKey(t), newTestSigningKey(t), jwt.SigningMethodES256, testRotationPeriod, testRetirementPeriod, start); err == nil {
+		t.Errorf("LoadKeyRing() with wrong key encryption key = nil, want error")
+	}
+}
+
+func TestEncryptSigningKeyRoundTrip(t *testing.T) {
+	kek := newTestKeyEncryptionKey(t)
+	key := newTestSigningKey(t)
+	privateKeyDer, _ := x509.MarshalPKCS8PrivateKey(key.PrivateKey)
+
+	privateKeyPem, err := EncryptSigningKey(kek, key.KeyId, key.PrivateKey)
+	if err != nil {
+		t.Fatalf("EncryptSigningKey() = %v, want nil", err)
+	}
+	if !IsEncryptedSigningKey(privateKeyPem) {
+		t.Fatalf("IsEncryptedSigningKey() = false, want true")
+	}
+	decrypted, err := DecryptSigningKey(kek, key.KeyId, privateKeyPem)
+	if err != nil {
+		t.Fatalf("DecryptSigningKey() = %v, want nil", err)
+	}
+	if decryptedDer, _ := x509.MarshalPKCS8PrivateKey(decrypted); !bytes.Equal(decryptedDer, privateKeyDer) {
+		t.Errorf("DecryptSigningKey() returned another key")
+	}
+
+	// The key encryption key and the key ID are authenticated
+	if _, err := DecryptSigningKey(newTestKeyEncryptionKey(t), key.KeyId, privateKeyPem); err == nil {
+		t.Errorf("DecryptSigningKey() with wrong key encryption key = nil, want error")
+	}
+	if _, err := DecryptSigningKey(kek, "other", privateKeyPem); err == nil {
+		t.Errorf("DecryptSigningKey() with other key ID = nil, want error")
+	}
+
+	// Unencrypted keys of previous versions are accepted
+	legacyPem := string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: privateKeyDer}))
+	if IsEncryptedSigningKey(legacyPem) {
+		t.Errorf("IsEncryptedSigningKey() of unencrypted key = true, want false")
+	}
+	if _, err := DecryptSigningKey(kek, key.KeyId, legacyPem); err != nil {
+		t.Errorf("DecryptSigningKey() of unencrypted key = %v, want nil", err)
+	}
+}
@@ -8,7 +8,7 @@
 package ict
 
 import (
-	"log"
+	"log/slog"
 	"net/http"
 	"time"
 )
@@ -16,15 +16,17 @@ import (
 func Logger(inner http.Handler, name string) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		start := time.Now()
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
 
-		inner.ServeHTTP(w, r)
+		inner.ServeHTTP(recorder, r)
 
-		log.Printf(
-			"%s %s %s %s",
-			r.Method,
-			r.RequestURI,
-			name,
-			time.Since(start),
+		// The query is not logged, since it may contain secrets
+		slog.InfoContext(r.Context(), "request handled",
+			"method", r.Method,
+			"path", r.URL.Path,
+			"route", name,
+			"status", recorder.status,
+			"duration", time.Since(start),
 		)
 	})
 }
@@ -0,0 +1,172 @@
+/*
+ * OIDC² - Identity Certification Token Endpoint
+ *
+ * Endpoint for OpenID Connect's Identity Certification Token endpoint.
+ *
+ * API version: 0.5.0
+ */
+package ict
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Header which carries the ID of a request.
+const requestIdHeader = "X-Request-ID"
+
+// Maximum length of a request ID accepted from the client.
+const maxRequestIdLength = 64
+
+// Attribute keys whose values are secret or personal and never logged.
+var redactedLogKeys = map[string]bool{
+	"authorization":                true,
+	"token":                        true,
+	"access_token":                 true,
+	"bearer_token":                 true,
+	"id_token":                     true,
+	"pop_token":                    true,
+	"identity_certification_token": true,
+	"introspection_credentials":    true,
+	"password":                     true,
+	"secret":                       true,
+	"sub":                          true,
+	"name":                         true,
+	"given_name":                   true,
+	"family_name":                  true,
+	"middle_name":                  true,
+	"nickname":                     true,
+	"preferred_username":           true,
+	"email":                        true,
+	"phone_number":                 true,
+	"address":                      true,
+	"birthdate":                    true,
+	"claims":                       true,
+	"userinfo":                     true,
+	"introspection":                true,
+}
+
+// Patterns of secrets in log messages: JWTs, e.g., access tokens and Proof of Possession Tokens, and credentials of authorization headers.
+// Credentials are told apart from words by containing a digit, a symbol or an uppercase letter after the first character.
+var (
+	redactedJwtPattern        = regexp.MustCompile(`eyJ[A-Za-z0-9_-]*\.[A-Za-z0-9_-]*\.[A-Za-z0-9_-]*`)
+	redactedCredentialPattern = regexp.MustCompile(`\b([Bb]earer|BEARER|[Bb]asic|BASIC)\s+([A-Za-z0-9._~+/-]*[0-9._~+/-][A-Za-z0-9._~+/-]*|[A-Za-z0-9._~+/-]+[A-Z][A-Za-z0-9._~+/-]*)=*`)
+	requestIdPattern          = regexp.MustCompile(`^[A-Za-z0-9._-]+$`)
+)
+
+type requestIdContextKey struct{}
+
+// NewLogger creates a logger which writes records of the configured level and format to w.
+// Secrets and personal claims are redacted, and records of requests are annotated with the request and trace ID.
+func NewLogger(w io.Writer, config AppConfiguration) (*slog.Logger, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(config.LogLevel)); err != nil {
+		return nil, errors.New("log level '" + config.LogLevel + "' is not supported")
+	}
+	options := &slog.HandlerOptions{
+		Level:       level,
+		ReplaceAttr: redactLogAttr,
+	}
+
+	var handler slog.Handler
+	switch config.LogFormat {
+	case "json":
+		handler = slog.NewJSONHandler(w, options)
+	case "text":
+		handler = slog.NewTextHandler(w, options)
+	default:
+		return nil, errors.New("log format '" + config.LogFormat + "' is not supported")
+	}
+	return slog.New(&requestContextHandler{handler}), nil
+}
+
+// RedactLogValue removes JWTs and the credentials of authorization headers from a log value.
+func RedactLogValue(value string) string {
+	value = redactedJwtPattern.ReplaceAllString(value, "REDACTED")
+	return redactedCredentialPattern.ReplaceAllString(value, "$1 REDACTED")
+}
+
+// redactLogAttr redacts secret or personal attributes and secrets in string values, including the message.
+func redactLogAttr(_ []string, attr slog.Attr) slog.Attr {
+	if redactedLogKeys[strings.ToLower(attr.Key)] {
+		return slog.String(attr.Key, "REDACTED")
+	}
+	switch attr.Value.Kind() {
+	case slog.KindString:
+		return slog.String(attr.Key, RedactLogValue(attr.Value.String()))
+	case slog.KindAny:
+		if err, ok := attr.Value.Any().(error); ok {
+			return slog.String(attr.Key, RedactLogValue(err.Error()))
+		}
+	}
+	return attr
+}
+
+// requestContextHandler adds the request ID and the trace ID of the context to every record.
+type requestContextHandler struct {
+	slog.Handler
+}
+
+func (handler *requestContextHandler) Handle(ctx context.Context, record slog.Record) error {
+	if requestId := RequestIdFromContext(ctx); requestId != "" {
+		record.AddAttrs(slog.String("request_id", requestId))
+	}
+	if spanContext := trace.SpanContextFromContext(ctx); spanContext.IsValid() {
+		record.AddAttrs(slog.String("trace_id", spanContext.TraceID().String()))
+	}
+	return handler.Handler.Handle(ctx, record)
+}
+
+func (handler *requestContextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &requestContextHandler{handler.Handler.WithAttrs(attrs)}
+}
+
+func (handler *requestContextHandler) WithGroup(name string) slog.Handler {
+	return &requestContextHandler{handler.Handler.WithGroup(name)}
+}
+
+// RequestIdFromContext returns the ID of the request of ctx, or an empty string if none.
+func RequestIdFromContext(ctx context.Context) string {
+	requestId, _ := ctx.Value(requestIdContextKey{}).(string)
+	return requestId
+}
+
+// newRequestId generates a random request ID.
+func newRequestId() string {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(id)
+}
+
+// RequestId assigns an ID to every request and echoes it in the X-Request-ID response header.
+// A well-formed ID of the X-Request-ID request header, e.g., of a reverse proxy, is reused.
+func RequestId(inner http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestId := r.Header.Get(requestIdHeader)
+		if len(requestId) > maxRequestIdLength || !requestIdPattern.MatchString(requestId) {
+			requestId = newRequestId()
+		}
+		w.Header().Set(requestIdHeader, requestId)
+
+		ctx := context.WithValue(r.Context(), requestIdContextKey{}, requestId)
+		inner.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// logFatal logs a failure which prevents the endpoint from running and exits.
+func logFatal(msg string, err error) {
+	slog.Error(msg, "error", err)
+	os.Exit(1)
+}
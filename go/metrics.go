@@ -0,0 +1,189 @@
+/*
+ * OIDC² - Identity Certification Token Endpoint
+ *
+ * Endpoint for OpenID Connect's Identity Certification Token endpoint.
+ *
+ * API version: 0.5.0
+ */
+package ict
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Endpoints of the OpenID Provider by metric label.
+const (
+	upstreamUserinfo      = "userinfo"
+	upstreamIntrospection = "introspection"
+)
+
+// Prometheus metrics.
+var (
+	httpRequestsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "ict_http_requests_total",
+		Help: "Number of handled HTTP requests by route, method and status code.",
+	}, []string{"route", "method", "status"})
+	httpRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "ict_http_request_duration_seconds",
+		Help:    "Latency of handled HTTP requests by route and status code.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"route", "status"})
+	upstreamRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "ict_upstream_request_duration_seconds",
+		Help:    "Latency of requests to the OpenID Provider by endpoint.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"endpoint"})
+	upstreamErrorsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "ict_upstream_errors_total",
+		Help: "Number of failed requests to the OpenID Provider by endpoint.",
+	}, []string{"endpoint"})
+	popValidationFailuresTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "ict_pop_validation_failures_total",
+		Help: "Number of rejected proof of possession tokens by reason.",
+	}, []string{"reason"})
+	ictsIssuedTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "ict_issued_total",
+		Help: "Number of issued Identity Certification Tokens by end-to-end authentication context and signing algorithm.",
+	}, []string{"context", "alg"})
+	upstreamRetriesTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "ict_upstream_retries_total",
+		Help: "Number of retried requests to the OpenID Provider by endpoint URL.",
+	}, []string{"endpoint"})
+	upstreamRejectedTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "ict_upstream_rejected_total",
+		Help: "Number of requests to the OpenID Provider rejected by an open circuit breaker by endpoint URL.",
+	}, []string{"endpoint"})
+	tokenCacheHitsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "ict_token_cache_hits_total",
+		Help: "Number of responses of the OpenID Provider answered from the token cache by kind.",
+	}, []string{"kind"})
+	tokenCacheMissesTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "ict_token_cache_misses_total",
+		Help: "Number of responses of the OpenID Provider not found in the token cache by kind.",
+	}, []string{"kind"})
+	nonceSweepsTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "ict_nonce_sweeps_total",
+		Help: "Number of sweeps of expired nonces.",
+	})
+	noncesSweptTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "ict_nonces_swept_total",
+		Help: "Number of expired nonces deleted by sweeps.",
+	})
+	nonceSweepErrorsTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "ict_nonce_sweep_errors_total",
+		Help: "Number of failed sweeps of expired nonces.",
+	})
+)
+
+func init() {
+	promauto.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "ict_nonce_store_size",
+		Help: "Number of nonces in the nonce store, -1 if unknown, e.g., for the Redis nonce store.",
+	}, func() float64 {
+		sizer, ok := appNonceStore.(NonceStoreSizer)
+		if !ok {
+			return -1
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		size, err := sizer.Size(ctx)
+		if err != nil {
+			slog.Error("failed to get nonce store size", "error", err)
+			return -1
+		}
+		return float64(size)
+	})
+	prometheus.MustRegister(circuitBreakerCollector{
+		desc: prometheus.NewDesc(
+			"ict_upstream_circuit_breaker_state",
+			"State of the circuit breaker of each requested endpoint of the OpenID Provider, 1 for the current state and 0 otherwise.",
+			[]string{"endpoint", "state"}, nil,
+		),
+	})
+}
+
+// circuitBreakerCollector exports the current states of the circuit breakers on every scrape.
+type circuitBreakerCollector struct {
+	desc *prometheus.Desc
+}
+
+func (collector circuitBreakerCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- collector.desc
+}
+
+func (collector circuitBreakerCollector) Collect(ch chan<- prometheus.Metric) {
+	for endpoint, current := range CircuitBreakerStates() {
+		for _, state := range []string{CircuitBreakerClosed, CircuitBreakerOpen, CircuitBreakerHalfOpen} {
+			value := 0.0
+			if state == current {
+				value = 1
+			}
+			ch <- prometheus.MustNewConstMetric(collector.desc, prometheus.GaugeValue, value, redactUrl(endpoint), state)
+		}
+	}
+}
+
+// GetMetrics serves the Prometheus metrics.
+var GetMetrics = promhttp.Handler().ServeHTTP
+
+// statusRecorder records the status code written by a handler.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (recorder *statusRecorder) WriteHeader(status int) {
+	recorder.status = status
+	recorder.ResponseWriter.WriteHeader(status)
+}
+
+// Metrics counts the requests of a route and measures their latency.
+func Metrics(inner http.Handler, name string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+
+		inner.ServeHTTP(recorder, r)
+
+		status := strconv.Itoa(recorder.status)
+		httpRequestsTotal.WithLabelValues(name, r.Method, status).Inc()
+		httpRequestDuration.WithLabelValues(name, status).Observe(time.Since(start).Seconds())
+	})
+}
+
+// observeUpstreamRequest records the latency and the outcome of a request to an endpoint of the OpenID Provider.
+func observeUpstreamRequest(endpoint string, start time.Time, failed bool) {
+	upstreamRequestDuration.WithLabelValues(endpoint).Observe(time.Since(start).Seconds())
+	if failed {
+		upstreamErrorsTotal.WithLabelValues(endpoint).Inc()
+	}
+}
+
+// recordPopFailure counts a rejected proof of possession token by its reason.
+// Errors which do not reject the token itself, e.g., of the nonce store, are not counted.
+func recordPopFailure(err error) {
+	var popErr *PopValidationError
+	if errors.As(err, &popErr) {
+		popValidationFailuresTotal.WithLabelValues(popErr.Reason).Inc()
+	}
+}
+
+// recordIssuedIct counts an issued Identity Certification Token per granted context.
+func recordIssuedIct(contexts []string, alg string) {
+	if len(contexts) == 0 {
+		ictsIssuedTotal.WithLabelValues("", alg).Inc()
+		return
+	}
+	for _, context := range contexts {
+		ictsIssuedTotal.WithLabelValues(context, alg).Inc()
+	}
+}
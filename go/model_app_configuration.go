@@ -8,43 +8,254 @@
 package ict
 
 import (
+	"encoding/json"
 	"errors"
+	"fmt"
+	"io"
+	"net/url"
 	"os"
 	"strconv"
+	"strings"
 
 	"github.com/golang-jwt/jwt/v4"
+	"gopkg.in/yaml.v3"
 )
 
 type AppConfiguration struct {
 	KeyFilePath                string            `json:"keyFilePath"`
 	KeyId                      string            `json:"keyId"`
+	KeyRotationPeriod          uint64            `json:"keyRotationPeriod"`
+	KeyEncryptionKeyFile       string            `json:"keyEncryptionKeyFile"`
 	SigningAlgorithm           jwt.SigningMethod `json:"alg"`
 	UserinfoEndpoint           string            `json:"userinfoEndpoint"`
 	UserinfoHost               string            `json:"userinfoHost"`
 	TokenIntrospectionEndpoint string            `json:"tokenIntrospectionEndpoint"`
 	TokenIntrospectionHost     string            `json:"tokenIntrospectionHost"`
 	IntrospectionCredentials   string            `json:"introspectionCredentials"`
+	AccessTokenValidation      string            `json:"accessTokenValidation"`
+	JwksUri                    string            `json:"jwksUri"`
+	AccessTokenIssuer          string            `json:"accessTokenIssuer"`
+	AccessTokenAudience        string            `json:"accessTokenAudience"`
+	TokenCacheTtl              uint64            `json:"tokenCacheTtl"`
+	TokenCacheSize             uint64            `json:"tokenCacheSize"`
+	HttpConnectTimeout         uint64            `json:"httpConnectTimeout"`
+	HttpReadTimeout            uint64            `json:"httpReadTimeout"`
+	HttpMaxIdleConnections     uint64            `json:"httpMaxIdleConnections"`
+	HttpCaFile                 string            `json:"httpCaFile"`
+	HttpClientCertFile         string            `json:"httpClientCertFile"`
+	HttpClientKeyFile          string            `json:"httpClientKeyFile"`
+	HttpProxyUrl               string            `json:"httpProxyUrl"`
+	OpRetries                  uint64            `json:"opRetries"`
+	OpBreakerThreshold         uint64            `json:"opBreakerThreshold"`
+	OpBreakerOpenPeriod        uint64            `json:"opBreakerOpenPeriod"`
+	RequestTimeout             uint64            `json:"requestTimeout"`
+	ServerReadTimeout          uint64            `json:"serverReadTimeout"`
+	ServerReadHeaderTimeout    uint64            `json:"serverReadHeaderTimeout"`
+	ServerWriteTimeout         uint64            `json:"serverWriteTimeout"`
+	ServerIdleTimeout          uint64            `json:"serverIdleTimeout"`
+	ServerMaxHeaderBytes       uint64            `json:"serverMaxHeaderBytes"`
+	ShutdownTimeout            uint64            `json:"shutdownTimeout"`
+	TlsCertFile                string            `json:"tlsCertFile"`
+	TlsKeyFile                 string            `json:"tlsKeyFile"`
+	TlsClientCaFile            string            `json:"tlsClientCaFile"`
+	TlsClientAuth              string            `json:"tlsClientAuth"`
+	TlsMinVersion              string            `json:"tlsMinVersion"`
 	ContextPrefix              string            `json:"contextPrefix"`
+	EndpointUrl                string            `json:"endpointUrl"`
+	TrustForwardedHeaders      bool              `json:"trustForwardedHeaders"`
+	NonceStore                 string            `json:"nonceStore"`
+	RedisUrl                   string            `json:"redisUrl"`
+	NonceSweepInterval         uint64            `json:"nonceSweepInterval"`
+	NonceSweepBatchSize        uint64            `json:"nonceSweepBatchSize"`
 	Issuer                     string            `json:"issuer"`
 	DefaultTokenPeriod         uint64            `json:"defaultTokenPeriod"`
 	MaxTokenPeriod             uint32            `json:"maxTokenPeriod"`
+	DatabaseFile               string            `json:"databaseFile"`
+	Port                       string            `json:"port"`
+	AdminPort                  string            `json:"adminPort"`
+	OtlpEndpoint               string            `json:"otlpEndpoint"`
+	TracingSampleRatio         float64           `json:"tracingSampleRatio"`
+	LogLevel                   string            `json:"logLevel"`
+	LogFormat                  string            `json:"logFormat"`
+	ReadinessCacheTtl          uint64            `json:"readinessCacheTtl"`
+	AuditSink                  string            `json:"auditSink"`
+	AuditFile                  string            `json:"auditFile"`
+	AuditHmacKeyFile           string            `json:"auditHmacKeyFile"`
+}
+
+// Attributes of the configuration file and the environment variables which override them.
+var appConfigurationVariables = []struct {
+	attribute string
+	variable  string
+}{
+	{"keyFilePath", "KEY_FILE"},
+	{"keyId", "KID"},
+	{"keyRotationPeriod", "KEY_ROTATION_PERIOD"},
+	{"keyEncryptionKeyFile", "KEY_ENCRYPTION_KEY_FILE"},
+	{"alg", "ALG"},
+	{"userinfoEndpoint", "USERINFO"},
+	{"userinfoHost", "USERINFO_HOST"},
+	{"tokenIntrospectionEndpoint", "TOKEN_INTROSPECTION"},
+	{"tokenIntrospectionHost", "TOKEN_INTROSPECTION_HOST"},
+	{"introspectionCredentials", "INTROSPECTION_CREDENTIALS"},
+	{"accessTokenValidation", "ACCESS_TOKEN_VALIDATION"},
+	{"jwksUri", "OP_JWKS_URI"},
+	{"accessTokenIssuer", "ACCESS_TOKEN_ISSUER"},
+	{"accessTokenAudience", "ACCESS_TOKEN_AUDIENCE"},
+	{"tokenCacheTtl", "TOKEN_CACHE_TTL"},
+	{"tokenCacheSize", "TOKEN_CACHE_SIZE"},
+	{"httpConnectTimeout", "HTTP_CONNECT_TIMEOUT"},
+	{"httpReadTimeout", "HTTP_READ_TIMEOUT"},
+	{"httpMaxIdleConnections", "HTTP_MAX_IDLE_CONNECTIONS"},
+	{"httpCaFile", "HTTP_CA_FILE"},
+	{"httpClientCertFile", "HTTP_CLIENT_CERT_FILE"},
+	{"httpClientKeyFile", "HTTP_CLIENT_KEY_FILE"},
+	{"httpProxyUrl", "HTTP_PROXY_URL"},
+	{"opRetries", "OP_RETRIES"},
+	{"opBreakerThreshold", "OP_BREAKER_THRESHOLD"},
+	{"opBreakerOpenPeriod", "OP_BREAKER_OPEN_PERIOD"},
+	{"requestTimeout", "REQUEST_TIMEOUT"},
+	{"serverReadTimeout", "SERVER_READ_TIMEOUT"},
+	{"serverReadHeaderTimeout", "SERVER_READ_HEADER_TIMEOUT"},
+	{"serverWriteTimeout", "SERVER_WRITE_TIMEOUT"},
+	{"serverIdleTimeout", "SERVER_IDLE_TIMEOUT"},
+	{"serverMaxHeaderBytes", "SERVER_MAX_HEADER_BYTES"},
+	{"shutdownTimeout", "SHUTDOWN_TIMEOUT"},
+	{"tlsCertFile", "TLS_CERT_FILE"},
+	{"tlsKeyFile", "TLS_KEY_FILE"},
+	{"tlsClientCaFile", "TLS_CLIENT_CA_FILE"},
+	{"tlsClientAuth", "TLS_CLIENT_AUTH"},
+	{"tlsMinVersion", "TLS_MIN_VERSION"},
+	{"contextPrefix", "CONTEXT_PREFIX"},
+	{"endpointUrl", "ENDPOINT_URL"},
+	{"trustForwardedHeaders", "TRUST_FORWARDED_HEADERS"},
+	{"nonceStore", "NONCE_STORE"},
+	{"redisUrl", "REDIS_URL"},
+	{"nonceSweepInterval", "NONCE_SWEEP_INTERVAL"},
+	{"nonceSweepBatchSize", "NONCE_SWEEP_BATCH_SIZE"},
+	{"issuer", "ISSUER"},
+	{"defaultTokenPeriod", "DEFAULT_TOKEN_PERIOD"},
+	{"maxTokenPeriod", "MAX_TOKEN_PERIOD"},
+	{"databaseFile", "DB_SQLITE_FILE"},
+	{"port", "PORT"},
+	{"adminPort", "ADMIN_PORT"},
+	{"otlpEndpoint", "OTLP_ENDPOINT"},
+	{"tracingSampleRatio", "TRACING_SAMPLE_RATIO"},
+	{"logLevel", "LOG_LEVEL"},
+	{"logFormat", "LOG_FORMAT"},
+	{"readinessCacheTtl", "READINESS_CACHE_TTL"},
+	{"auditSink", "AUDIT_SINK"},
+	{"auditFile", "AUDIT_FILE"},
+	{"auditHmacKeyFile", "AUDIT_HMAC_KEY_FILE"},
+}
+
+// Raw configuration values by environment variable name.
+type appConfigurationSource map[string]string
+
+func (source appConfigurationSource) get(variable string) string {
+	return source[variable]
+}
+
+func (source appConfigurationSource) notFound(variable string) string {
+	for _, v := range appConfigurationVariables {
+		if v.variable == variable {
+			return "environment variable '" + variable + "' or configuration file attribute '" + v.attribute + "' not found"
+		}
+	}
+	return "environment variable '" + variable + "' not found"
+}
+
+// readAppConfigurationFile reads the attributes of a YAML or JSON configuration file.
+func readAppConfigurationFile(configFile string, source appConfigurationSource) []error {
+	data, err := os.ReadFile(configFile)
+	if err != nil {
+		return []error{errors.New("failed to read configuration file: " + err.Error())}
+	}
+
+	// JSON is a subset of YAML, so the YAML parser reads both formats
+	var attributes map[string]interface{}
+	if err = yaml.Unmarshal(data, &attributes); err != nil {
+		return []error{errors.New("failed to parse configuration file '" + configFile + "': " + err.Error())}
+	}
+
+	var errs []error
+	for attribute, value := range attributes {
+		// Find environment variable of attribute
+		variable := ""
+		for _, v := range appConfigurationVariables {
+			if v.attribute == attribute {
+				variable = v.variable
+			}
+		}
+		if variable == "" {
+			errs = append(errs, errors.New("failed to read configuration file: unknown attribute '"+attribute+"'"))
+			continue
+		}
+
+		// Convert scalar value to string
+		switch value := value.(type) {
+		case nil:
+		case string:
+			source[variable] = value
+		case int, int64, uint64, float64, bool:
+			source[variable] = fmt.Sprint(value)
+		default:
+			errs = append(errs, errors.New("failed to read configuration file: attribute '"+attribute+"' must be a string, number or boolean"))
+		}
+	}
+	return errs
 }
 
 func LoadAppConfigurationFromEnv() (AppConfiguration, error) {
+	return LoadAppConfiguration("")
+}
+
+// LoadAppConfiguration loads the configuration from the YAML or JSON file configFile, if not empty.
+// Environment variables override the values from the file.
+// The returned error lists all problems found, the returned configuration holds all values which could be resolved.
+func LoadAppConfiguration(configFile string) (AppConfiguration, error) {
+	var errs []error
+	source := appConfigurationSource{}
+
+	// Read configuration file
+	if configFile != "" {
+		errs = append(errs, readAppConfigurationFile(configFile, source)...)
+	}
+
+	// Override with environment variables
+	for _, v := range appConfigurationVariables {
+		if value := os.Getenv(v.variable); value != "" {
+			source[v.variable] = value
+		}
+	}
+
 	// Parse key file path
-	keyFilePath := os.Getenv("KEY_FILE")
+	keyFilePath := source.get("KEY_FILE")
 	if keyFilePath == "" {
-		return AppConfiguration{}, errors.New("failed to load key file path: environment variable 'KEY_FILE' not found")
+		errs = append(errs, errors.New("failed to load key file path: "+source.notFound("KEY_FILE")))
 	}
 
 	// Parse key ID
-	keyId := os.Getenv("KID")
+	keyId := source.get("KID")
 	if keyId == "" {
-		return AppConfiguration{}, errors.New("failed to load key id: environment variable 'KID' not found")
+		errs = append(errs, errors.New("failed to load key id: "+source.notFound("KID")))
+	}
+
+	// Parse key rotation period
+	keyRotationPeriodString := source.get("KEY_ROTATION_PERIOD")
+	if keyRotationPeriodString == "" {
+		keyRotationPeriodString = "0"
+	}
+	keyRotationPeriod, err := strconv.ParseUint(keyRotationPeriodString, 10, 64)
+	if err != nil {
+		errs = append(errs, errors.New("failed to load key rotation period: value '"+keyRotationPeriodString+"' is not a non-negative integer"))
 	}
 
+	// Parse key encryption key file path
+	keyEncryptionKeyFile := source.get("KEY_ENCRYPTION_KEY_FILE")
+
 	// Parse signing algorithm
-	signingAlgorithmString := os.Getenv("ALG")
+	signingAlgorithmString := source.get("ALG")
 	if signingAlgorithmString == "" {
 		signingAlgorithmString = "ES256"
 	}
@@ -65,79 +276,669 @@ func LoadAppConfigurationFromEnv() (AppConfiguration, error) {
 	case "EdDSA":
 		signingAlgorithm = jwt.SigningMethodEdDSA
 	default:
-		return AppConfiguration{}, errors.New("failed to load signing algorithm: signing algorithm '" + signingAlgorithmString + "' is not supported")
+		errs = append(errs, errors.New("failed to load signing algorithm: signing algorithm '"+signingAlgorithmString+"' is not supported"))
 	}
 
 	// Parse userinfo endpoint
-	userinfoEndpoint := os.Getenv("USERINFO")
+	userinfoEndpoint := source.get("USERINFO")
 	if userinfoEndpoint == "" {
-		return AppConfiguration{}, errors.New("failed to read userinfo endpoint: environment variable 'USERINFO' not found")
+		errs = append(errs, errors.New("failed to read userinfo endpoint: "+source.notFound("USERINFO")))
 	}
 
-	userinfoHost := os.Getenv("TOKEN_INTROSPECTION_HOST")
+	// Parse userinfo host header
+	userinfoHost := source.get("USERINFO_HOST")
+
+	// Parse access token validation mode
+	accessTokenValidation := source.get("ACCESS_TOKEN_VALIDATION")
+	if accessTokenValidation == "" {
+		accessTokenValidation = "introspection"
+	}
+	switch accessTokenValidation {
+	case "introspection", "jwt":
+	default:
+		errs = append(errs, errors.New("failed to load access token validation: mode '"+accessTokenValidation+"' is not supported"))
+	}
 
 	// Parse token introspection endpoint
-	tokenIntrospectionEndpoint := os.Getenv("TOKEN_INTROSPECTION")
-	if tokenIntrospectionEndpoint == "" {
-		return AppConfiguration{}, errors.New("failed to read token introspection endpoint: environment variable 'TOKEN_INTROSPECTION' not found")
+	tokenIntrospectionEndpoint := source.get("TOKEN_INTROSPECTION")
+	if tokenIntrospectionEndpoint == "" && accessTokenValidation != "jwt" {
+		errs = append(errs, errors.New("failed to read token introspection endpoint: "+source.notFound("TOKEN_INTROSPECTION")))
 	}
 
 	// Parse token introspection host header
-	tokenIntrospectionHost := os.Getenv("TOKEN_INTROSPECTION_HOST")
+	tokenIntrospectionHost := source.get("TOKEN_INTROSPECTION_HOST")
 
 	// Parse token introspection credentials
-	introspectionCredentials := os.Getenv("INTROSPECTION_CREDENTIALS")
-	if introspectionCredentials == "" {
-		return AppConfiguration{}, errors.New("failed to read token introspection credentials: environment variable 'INTROSPECTION_CREDENTIALS' not found")
+	introspectionCredentials := source.get("INTROSPECTION_CREDENTIALS")
+	if introspectionCredentials == "" && accessTokenValidation != "jwt" {
+		errs = append(errs, errors.New("failed to read token introspection credentials: "+source.notFound("INTROSPECTION_CREDENTIALS")))
+	}
+
+	// Parse JWK set URI of the OpenID Provider
+	jwksUri := source.get("OP_JWKS_URI")
+	if jwksUri == "" && accessTokenValidation == "jwt" {
+		errs = append(errs, errors.New("failed to read JWK set URI: "+source.notFound("OP_JWKS_URI")))
+	}
+
+	// Parse expected access token audience
+	accessTokenAudience := source.get("ACCESS_TOKEN_AUDIENCE")
+
+	// Parse token cache TTL
+	tokenCacheTtlString := source.get("TOKEN_CACHE_TTL")
+	if tokenCacheTtlString == "" {
+		tokenCacheTtlString = "60"
+	}
+	tokenCacheTtl, err := strconv.ParseUint(tokenCacheTtlString, 10, 32)
+	if err != nil {
+		errs = append(errs, errors.New("failed to load token cache TTL: value '"+tokenCacheTtlString+"' is not a non-negative integer"))
+	}
+
+	// Parse token cache size
+	tokenCacheSizeString := source.get("TOKEN_CACHE_SIZE")
+	if tokenCacheSizeString == "" {
+		tokenCacheSizeString = "10000"
+	}
+	tokenCacheSize, err := strconv.ParseUint(tokenCacheSizeString, 10, 31)
+	if err != nil || tokenCacheSize == 0 {
+		errs = append(errs, errors.New("failed to load token cache size: value '"+tokenCacheSizeString+"' is not a positive integer"))
+	}
+
+	// Parse HTTP client connect timeout
+	httpConnectTimeoutString := source.get("HTTP_CONNECT_TIMEOUT")
+	if httpConnectTimeoutString == "" {
+		httpConnectTimeoutString = "5"
+	}
+	httpConnectTimeout, err := strconv.ParseUint(httpConnectTimeoutString, 10, 32)
+	if err != nil || httpConnectTimeout == 0 {
+		errs = append(errs, errors.New("failed to load HTTP connect timeout: value '"+httpConnectTimeoutString+"' is not a positive integer"))
+	}
+
+	// Parse HTTP client read timeout
+	httpReadTimeoutString := source.get("HTTP_READ_TIMEOUT")
+	if httpReadTimeoutString == "" {
+		httpReadTimeoutString = "10"
+	}
+	httpReadTimeout, err := strconv.ParseUint(httpReadTimeoutString, 10, 32)
+	if err != nil || httpReadTimeout == 0 {
+		errs = append(errs, errors.New("failed to load HTTP read timeout: value '"+httpReadTimeoutString+"' is not a positive integer"))
+	}
+
+	// Parse HTTP client connection pool size
+	httpMaxIdleConnectionsString := source.get("HTTP_MAX_IDLE_CONNECTIONS")
+	if httpMaxIdleConnectionsString == "" {
+		httpMaxIdleConnectionsString = "100"
+	}
+	httpMaxIdleConnections, err := strconv.ParseUint(httpMaxIdleConnectionsString, 10, 31)
+	if err != nil {
+		errs = append(errs, errors.New("failed to load HTTP max idle connections: value '"+httpMaxIdleConnectionsString+"' is not a non-negative integer"))
+	}
+
+	// Parse HTTP client TLS files and proxy
+	httpCaFile := source.get("HTTP_CA_FILE")
+	httpClientCertFile := source.get("HTTP_CLIENT_CERT_FILE")
+	httpClientKeyFile := source.get("HTTP_CLIENT_KEY_FILE")
+	httpProxyUrl := source.get("HTTP_PROXY_URL")
+
+	// Parse retries of OpenID Provider requests
+	opRetriesString := source.get("OP_RETRIES")
+	if opRetriesString == "" {
+		opRetriesString = "2"
+	}
+	opRetries, err := strconv.ParseUint(opRetriesString, 10, 8)
+	if err != nil {
+		errs = append(errs, errors.New("failed to load OpenID Provider retries: value '"+opRetriesString+"' is not a non-negative integer below 256"))
+	}
+
+	// Parse circuit breaker threshold of OpenID Provider requests
+	opBreakerThresholdString := source.get("OP_BREAKER_THRESHOLD")
+	if opBreakerThresholdString == "" {
+		opBreakerThresholdString = "5"
+	}
+	opBreakerThreshold, err := strconv.ParseUint(opBreakerThresholdString, 10, 31)
+	if err != nil {
+		errs = append(errs, errors.New("failed to load OpenID Provider circuit breaker threshold: value '"+opBreakerThresholdString+"' is not a non-negative integer"))
+	}
+
+	// Parse circuit breaker open period of OpenID Provider requests
+	opBreakerOpenPeriodString := source.get("OP_BREAKER_OPEN_PERIOD")
+	if opBreakerOpenPeriodString == "" {
+		opBreakerOpenPeriodString = "30"
+	}
+	opBreakerOpenPeriod, err := strconv.ParseUint(opBreakerOpenPeriodString, 10, 32)
+	if err != nil || opBreakerOpenPeriod == 0 {
+		errs = append(errs, errors.New("failed to load OpenID Provider circuit breaker open period: value '"+opBreakerOpenPeriodString+"' is not a positive integer"))
+	}
+
+	// Parse request timeout
+	requestTimeoutString := source.get("REQUEST_TIMEOUT")
+	if requestTimeoutString == "" {
+		requestTimeoutString = "30"
+	}
+	requestTimeout, err := strconv.ParseUint(requestTimeoutString, 10, 32)
+	if err != nil {
+		errs = append(errs, errors.New("failed to load request timeout: value '"+requestTimeoutString+"' is not a non-negative integer"))
+	}
+
+	// Parse server read timeout
+	serverReadTimeoutString := source.get("SERVER_READ_TIMEOUT")
+	if serverReadTimeoutString == "" {
+		serverReadTimeoutString = "15"
+	}
+	serverReadTimeout, err := strconv.ParseUint(serverReadTimeoutString, 10, 32)
+	if err != nil {
+		errs = append(errs, errors.New("failed to load server read timeout: value '"+serverReadTimeoutString+"' is not a non-negative integer"))
+	}
+
+	// Parse server read header timeout
+	serverReadHeaderTimeoutString := source.get("SERVER_READ_HEADER_TIMEOUT")
+	if serverReadHeaderTimeoutString == "" {
+		serverReadHeaderTimeoutString = "5"
+	}
+	serverReadHeaderTimeout, err := strconv.ParseUint(serverReadHeaderTimeoutString, 10, 32)
+	if err != nil || serverReadHeaderTimeout == 0 {
+		errs = append(errs, errors.New("failed to load server read header timeout: value '"+serverReadHeaderTimeoutString+"' is not a positive integer"))
+	}
+
+	// Parse server write timeout
+	serverWriteTimeoutString := source.get("SERVER_WRITE_TIMEOUT")
+	if serverWriteTimeoutString == "" {
+		serverWriteTimeoutString = "45"
+	}
+	serverWriteTimeout, err := strconv.ParseUint(serverWriteTimeoutString, 10, 32)
+	if err != nil {
+		errs = append(errs, errors.New("failed to load server write timeout: value '"+serverWriteTimeoutString+"' is not a non-negative integer"))
+	}
+
+	// Parse server idle timeout
+	serverIdleTimeoutString := source.get("SERVER_IDLE_TIMEOUT")
+	if serverIdleTimeoutString == "" {
+		serverIdleTimeoutString = "120"
+	}
+	serverIdleTimeout, err := strconv.ParseUint(serverIdleTimeoutString, 10, 32)
+	if err != nil {
+		errs = append(errs, errors.New("failed to load server idle timeout: value '"+serverIdleTimeoutString+"' is not a non-negative integer"))
+	}
+
+	// Parse server max header bytes
+	serverMaxHeaderBytesString := source.get("SERVER_MAX_HEADER_BYTES")
+	if serverMaxHeaderBytesString == "" {
+		serverMaxHeaderBytesString = "16384"
+	}
+	serverMaxHeaderBytes, err := strconv.ParseUint(serverMaxHeaderBytesString, 10, 31)
+	if err != nil || serverMaxHeaderBytes == 0 {
+		errs = append(errs, errors.New("failed to load server max header bytes: value '"+serverMaxHeaderBytesString+"' is not a positive integer"))
+	}
+
+	// Parse shutdown timeout
+	shutdownTimeoutString := source.get("SHUTDOWN_TIMEOUT")
+	if shutdownTimeoutString == "" {
+		shutdownTimeoutString = "30"
+	}
+	shutdownTimeout, err := strconv.ParseUint(shutdownTimeoutString, 10, 32)
+	if err != nil || shutdownTimeout == 0 {
+		errs = append(errs, errors.New("failed to load shutdown timeout: value '"+shutdownTimeoutString+"' is not a positive integer"))
+	}
+
+	// Parse TLS files
+	tlsCertFile := source.get("TLS_CERT_FILE")
+	tlsKeyFile := source.get("TLS_KEY_FILE")
+	tlsClientCaFile := source.get("TLS_CLIENT_CA_FILE")
+
+	// Parse TLS client authentication
+	tlsClientAuth := source.get("TLS_CLIENT_AUTH")
+	if tlsClientAuth == "" {
+		tlsClientAuth = "none"
+	}
+	if _, ok := TlsClientAuthFromString(tlsClientAuth); !ok {
+		errs = append(errs, errors.New("failed to load TLS client authentication: value '"+tlsClientAuth+"' is not supported"))
+	}
+
+	// Parse minimum TLS version
+	tlsMinVersion := source.get("TLS_MIN_VERSION")
+	if tlsMinVersion == "" {
+		tlsMinVersion = "1.2"
+	}
+	if _, ok := TlsVersionFromString(tlsMinVersion); !ok {
+		errs = append(errs, errors.New("failed to load minimum TLS version: value '"+tlsMinVersion+"' is not supported"))
 	}
 
 	// Parse custom context prefix
-	contextPrefix := os.Getenv("CONTEXT_PREFIX")
+	contextPrefix := source.get("CONTEXT_PREFIX")
 	if contextPrefix == "" {
 		contextPrefix = "e2e_ctx_"
 	}
 
+	// Parse public endpoint URL
+	endpointUrl := source.get("ENDPOINT_URL")
+
+	// Parse whether to trust headers set by a reverse proxy, which is ignored but still accepted
+	trustForwardedHeadersString := source.get("TRUST_FORWARDED_HEADERS")
+	if trustForwardedHeadersString == "" {
+		trustForwardedHeadersString = "false"
+	}
+	trustForwardedHeaders, err := strconv.ParseBool(trustForwardedHeadersString)
+	if err != nil {
+		errs = append(errs, errors.New("failed to load trust forwarded headers: value '"+trustForwardedHeadersString+"' is not a boolean"))
+	}
+
+	// Parse nonce store
+	nonceStore := source.get("NONCE_STORE")
+	if nonceStore == "" {
+		nonceStore = "sqlite"
+	}
+	switch nonceStore {
+	case "sqlite", "memory", "redis":
+	default:
+		errs = append(errs, errors.New("failed to load nonce store: nonce store '"+nonceStore+"' is not supported"))
+	}
+
+	// Parse Redis URL
+	redisUrl := source.get("REDIS_URL")
+	if nonceStore == "redis" && redisUrl == "" {
+		errs = append(errs, errors.New("failed to load Redis URL: "+source.notFound("REDIS_URL")))
+	}
+
+	// Parse nonce sweep interval
+	nonceSweepIntervalString := source.get("NONCE_SWEEP_INTERVAL")
+	if nonceSweepIntervalString == "" {
+		nonceSweepIntervalString = "300"
+	}
+	nonceSweepInterval, err := strconv.ParseUint(nonceSweepIntervalString, 10, 64)
+	if err != nil {
+		errs = append(errs, errors.New("failed to load nonce sweep interval: value '"+nonceSweepIntervalString+"' is not a non-negative integer"))
+	}
+
+	// Parse nonce sweep batch size
+	nonceSweepBatchSizeString := source.get("NONCE_SWEEP_BATCH_SIZE")
+	if nonceSweepBatchSizeString == "" {
+		nonceSweepBatchSizeString = "1000"
+	}
+	nonceSweepBatchSize, err := strconv.ParseUint(nonceSweepBatchSizeString, 10, 31)
+	if err != nil || nonceSweepBatchSize == 0 {
+		errs = append(errs, errors.New("failed to load nonce sweep batch size: value '"+nonceSweepBatchSizeString+"' is not a positive integer"))
+	}
+
 	// Parse issuer
-	issuer := os.Getenv("ISSUER")
-	if userinfoEndpoint == "" {
-		return AppConfiguration{}, errors.New("failed to load issuer: environment variable 'ISSUER' not found")
+	issuer := source.get("ISSUER")
+	if issuer == "" {
+		errs = append(errs, errors.New("failed to load issuer: "+source.notFound("ISSUER")))
+	}
+
+	// Parse expected access token issuer
+	accessTokenIssuer := source.get("ACCESS_TOKEN_ISSUER")
+	if accessTokenIssuer == "" {
+		accessTokenIssuer = issuer
 	}
 
 	// Parse default token period
-	defaultTokenPeriodString := os.Getenv("DEFAULT_TOKEN_PERIOD")
+	defaultTokenPeriodString := source.get("DEFAULT_TOKEN_PERIOD")
 	if defaultTokenPeriodString == "" {
 		defaultTokenPeriodString = "3600"
 	}
-	defaultTokenPeriodInt, err := strconv.Atoi(defaultTokenPeriodString)
+	defaultTokenPeriod, err := strconv.ParseUint(defaultTokenPeriodString, 10, 64)
 	if err != nil {
-		return AppConfiguration{}, errors.New("Failed to load default token period: value '" + defaultTokenPeriodString + "' is not an integer")
+		errs = append(errs, errors.New("failed to load default token period: value '"+defaultTokenPeriodString+"' is not a non-negative integer"))
 	}
-	defaultTokenPeriod := uint64(defaultTokenPeriodInt)
 
 	// Parse maximum token period
-	maxTokenPeriodString := os.Getenv("MAX_TOKEN_PERIOD")
+	maxTokenPeriodString := source.get("MAX_TOKEN_PERIOD")
 	if maxTokenPeriodString == "" {
 		maxTokenPeriodString = "2592000"
 	}
-	maxTokenPeriodInt, err := strconv.Atoi(maxTokenPeriodString)
+	maxTokenPeriodUint, err := strconv.ParseUint(maxTokenPeriodString, 10, 32)
+	if err != nil {
+		errs = append(errs, errors.New("failed to load maximum token period: value '"+maxTokenPeriodString+"' is not a non-negative 32 bit integer"))
+	}
+	maxTokenPeriod := uint32(maxTokenPeriodUint)
+
+	// Parse database file
+	databaseFile := source.get("DB_SQLITE_FILE")
+	if databaseFile == "" {
+		databaseFile = "./db.sqlite"
+	}
+
+	// Parse port
+	port := source.get("PORT")
+	if port == "" {
+		port = "8080"
+	}
+
+	// Parse admin port, empty to serve metrics on the main port
+	adminPort := source.get("ADMIN_PORT")
+
+	// Parse OTLP endpoint, empty to disable the export of traces
+	otlpEndpoint := source.get("OTLP_ENDPOINT")
+
+	// Parse tracing sample ratio
+	tracingSampleRatioString := source.get("TRACING_SAMPLE_RATIO")
+	if tracingSampleRatioString == "" {
+		tracingSampleRatioString = "1"
+	}
+	tracingSampleRatio, err := strconv.ParseFloat(tracingSampleRatioString, 64)
+	if err != nil || tracingSampleRatio < 0 || tracingSampleRatio > 1 {
+		errs = append(errs, errors.New("failed to load tracing sample ratio: value '"+tracingSampleRatioString+"' is not a number between 0 and 1"))
+	}
+
+	// Parse log level and format
+	logLevel := source.get("LOG_LEVEL")
+	if logLevel == "" {
+		logLevel = "info"
+	}
+	logFormat := source.get("LOG_FORMAT")
+	if logFormat == "" {
+		logFormat = "json"
+	}
+
+	// Parse readiness cache TTL
+	readinessCacheTtlString := source.get("READINESS_CACHE_TTL")
+	if readinessCacheTtlString == "" {
+		readinessCacheTtlString = "10"
+	}
+	readinessCacheTtl, err := strconv.ParseUint(readinessCacheTtlString, 10, 32)
 	if err != nil {
-		return AppConfiguration{}, errors.New("failed load maximum token period: value '" + maxTokenPeriodString + "' is not an integer")
+		errs = append(errs, errors.New("failed to load readiness cache TTL: value '"+readinessCacheTtlString+"' is not a non-negative integer"))
 	}
-	maxTokenPeriod := uint32(maxTokenPeriodInt)
 
-	// Return result
-	return AppConfiguration{
+	// Parse audit sink
+	auditSink := source.get("AUDIT_SINK")
+	if auditSink == "" {
+		auditSink = AuditSinkNone
+	}
+	switch auditSink {
+	case AuditSinkNone, AuditSinkFile, AuditSinkSqlite:
+	default:
+		errs = append(errs, errors.New("failed to load audit sink: audit sink '"+auditSink+"' is not supported"))
+	}
+
+	// Parse audit log file
+	auditFile := source.get("AUDIT_FILE")
+	if auditFile == "" {
+		auditFile = "./audit.jsonl"
+	}
+
+	// Parse audit HMAC key file path
+	auditHmacKeyFile := source.get("AUDIT_HMAC_KEY_FILE")
+
+	// Compose result
+	config := AppConfiguration{
 		KeyFilePath:                keyFilePath,
 		KeyId:                      keyId,
+		KeyRotationPeriod:          keyRotationPeriod,
+		KeyEncryptionKeyFile:       keyEncryptionKeyFile,
 		SigningAlgorithm:           signingAlgorithm,
 		UserinfoEndpoint:           userinfoEndpoint,
 		UserinfoHost:               userinfoHost,
 		TokenIntrospectionEndpoint: tokenIntrospectionEndpoint,
 		TokenIntrospectionHost:     tokenIntrospectionHost,
 		IntrospectionCredentials:   introspectionCredentials,
+		AccessTokenValidation:      accessTokenValidation,
+		JwksUri:                    jwksUri,
+		AccessTokenIssuer:          accessTokenIssuer,
+		AccessTokenAudience:        accessTokenAudience,
+		TokenCacheTtl:              tokenCacheTtl,
+		TokenCacheSize:             tokenCacheSize,
+		HttpConnectTimeout:         httpConnectTimeout,
+		HttpReadTimeout:            httpReadTimeout,
+		HttpMaxIdleConnections:     httpMaxIdleConnections,
+		HttpCaFile:                 httpCaFile,
+		HttpClientCertFile:         httpClientCertFile,
+		HttpClientKeyFile:          httpClientKeyFile,
+		HttpProxyUrl:               httpProxyUrl,
+		OpRetries:                  opRetries,
+		OpBreakerThreshold:         opBreakerThreshold,
+		OpBreakerOpenPeriod:        opBreakerOpenPeriod,
+		RequestTimeout:             requestTimeout,
+		ServerReadTimeout:          serverReadTimeout,
+		ServerReadHeaderTimeout:    serverReadHeaderTimeout,
+		ServerWriteTimeout:         serverWriteTimeout,
+		ServerIdleTimeout:          serverIdleTimeout,
+		ServerMaxHeaderBytes:       serverMaxHeaderBytes,
+		ShutdownTimeout:            shutdownTimeout,
+		TlsCertFile:                tlsCertFile,
+		TlsKeyFile:                 tlsKeyFile,
+		TlsClientCaFile:            tlsClientCaFile,
+		TlsClientAuth:              tlsClientAuth,
+		TlsMinVersion:              tlsMinVersion,
 		ContextPrefix:              contextPrefix,
+		EndpointUrl:                endpointUrl,
+		TrustForwardedHeaders:      trustForwardedHeaders,
+		NonceStore:                 nonceStore,
+		RedisUrl:                   redisUrl,
+		NonceSweepInterval:         nonceSweepInterval,
+		NonceSweepBatchSize:        nonceSweepBatchSize,
 		Issuer:                     issuer,
 		DefaultTokenPeriod:         defaultTokenPeriod,
 		MaxTokenPeriod:             maxTokenPeriod,
-	}, nil
+		DatabaseFile:               databaseFile,
+		Port:                       port,
+		AdminPort:                  adminPort,
+		OtlpEndpoint:               otlpEndpoint,
+		TracingSampleRatio:         tracingSampleRatio,
+		LogLevel:                   logLevel,
+		LogFormat:                  logFormat,
+		ReadinessCacheTtl:          readinessCacheTtl,
+		AuditSink:                  auditSink,
+		AuditFile:                  auditFile,
+		AuditHmacKeyFile:           auditHmacKeyFile,
+	}
+
+	// Validate result
+	errs = append(errs, ValidateAppConfiguration(config)...)
+	if len(errs) > 0 {
+		return config, errors.Join(errs...)
+	}
+	return config, nil
+}
+
+// Minimum key rotation period in seconds, since the key ring is maintained once per minute.
+const minKeyRotationPeriod = 60
+
+// ValidateAppConfiguration checks the semantic constraints of a loaded configuration.
+// Values which failed to load are skipped. The returned slice lists all problems found.
+func ValidateAppConfiguration(config AppConfiguration) []error {
+	var errs []error
+
+	// Validate key file
+	if config.KeyFilePath != "" && config.SigningAlgorithm != nil {
+		if _, err := ReadPrivateKey(config.KeyFilePath, config.SigningAlgorithm); err != nil {
+			errs = append(errs, errors.New("invalid key file: "+err.Error()))
+		}
+	}
+
+	// Validate key rotation period
+	if config.KeyRotationPeriod != 0 && config.KeyRotationPeriod < minKeyRotationPeriod {
+		errs = append(errs, fmt.Errorf("invalid key rotation period: must be 0 or at least %d seconds", minKeyRotationPeriod))
+	}
+
+	// Validate key encryption key, which protects the generated signing keys in the database
+	if config.KeyRotationPeriod != 0 {
+		if config.KeyEncryptionKeyFile == "" {
+			errs = append(errs, errors.New("invalid key encryption key file: required if key rotation is enabled"))
+		} else if _, err := ReadKeyEncryptionKey(config.KeyEncryptionKeyFile); err != nil {
+			errs = append(errs, errors.New("invalid key encryption key file: "+err.Error()))
+		}
+	}
+
+	// Validate audit HMAC key, which keys the hash chain of the audit log
+	if config.AuditHmacKeyFile != "" {
+		if _, err := ReadAuditHmacKey(config.AuditHmacKeyFile); err != nil {
+			errs = append(errs, errors.New("invalid audit HMAC key file: "+err.Error()))
+		}
+	}
+
+	// Signing keys are generated in the local database, so replicas sharing a nonce store would publish different keys
+	if config.KeyRotationPeriod != 0 && config.NonceStore == "redis" {
+		errs = append(errs, errors.New("invalid key rotation period: key rotation is not supported with the redis nonce store, use a static key file for multiple instances"))
+	}
+
+	// Validate URLs
+	if config.UserinfoEndpoint != "" {
+		if err := validateHttpUrl(config.UserinfoEndpoint); err != nil {
+			errs = append(errs, errors.New("invalid userinfo endpoint: "+err.Error()))
+		}
+	}
+	if config.TokenIntrospectionEndpoint != "" {
+		if err := validateHttpUrl(config.TokenIntrospectionEndpoint); err != nil {
+			errs = append(errs, errors.New("invalid token introspection endpoint: "+err.Error()))
+		}
+	}
+	if config.JwksUri != "" {
+		if err := validateHttpUrl(config.JwksUri); err != nil {
+			errs = append(errs, errors.New("invalid JWK set URI: "+err.Error()))
+		}
+	}
+	// The endpoint URL is required, since issued tokens reference the status list by a signed URL
+	if config.EndpointUrl == "" {
+		errs = append(errs, errors.New("invalid endpoint URL: required to reference the status list in issued tokens"))
+	} else if err := validateHttpUrl(config.EndpointUrl); err != nil {
+		errs = append(errs, errors.New("invalid endpoint URL: "+err.Error()))
+	}
+	if config.Issuer != "" {
+		if err := validateHttpUrl(config.Issuer); err != nil {
+			errs = append(errs, errors.New("invalid issuer: "+err.Error()))
+		}
+	}
+	if config.RedisUrl != "" {
+		if redisUrl, err := url.Parse(config.RedisUrl); err != nil || (redisUrl.Scheme != "redis" && redisUrl.Scheme != "rediss") {
+			errs = append(errs, errors.New("invalid Redis URL: scheme must be 'redis' or 'rediss'"))
+		}
+	}
+
+	if config.HttpProxyUrl != "" {
+		if err := validateHttpUrl(config.HttpProxyUrl); err != nil {
+			errs = append(errs, errors.New("invalid HTTP proxy URL: "+err.Error()))
+		}
+	}
+	if config.OtlpEndpoint != "" {
+		if err := validateHttpUrl(config.OtlpEndpoint); err != nil {
+			errs = append(errs, errors.New("invalid OTLP endpoint: "+err.Error()))
+		}
+	}
+
+	// Validate HTTP client
+	if _, err := NewHttpClient(config); err != nil {
+		errs = append(errs, errors.New("invalid HTTP client: "+err.Error()))
+	}
+
+	// Validate TLS
+	if _, ok := TlsVersionFromString(config.TlsMinVersion); ok {
+		if _, ok := TlsClientAuthFromString(config.TlsClientAuth); ok {
+			if _, err := NewTlsConfig(config); err != nil {
+				errs = append(errs, errors.New("invalid TLS configuration: "+err.Error()))
+			}
+		}
+	}
+
+	// Validate logging
+	if _, err := NewLogger(io.Discard, config); err != nil {
+		errs = append(errs, errors.New("invalid logging configuration: "+err.Error()))
+	}
+
+	// Validate host headers
+	if strings.ContainsAny(config.UserinfoHost, " /\t\r\n") {
+		errs = append(errs, errors.New("invalid userinfo host: value '"+config.UserinfoHost+"' is not a hostname"))
+	}
+	if strings.ContainsAny(config.TokenIntrospectionHost, " /\t\r\n") {
+		errs = append(errs, errors.New("invalid token introspection host: value '"+config.TokenIntrospectionHost+"' is not a hostname"))
+	}
+
+	// Validate context prefix, which must be usable as scope token
+	if strings.ContainsAny(config.ContextPrefix, " \"\\\t\r\n") {
+		errs = append(errs, errors.New("invalid context prefix: value '"+config.ContextPrefix+"' must not contain whitespace, quotes or backslashes"))
+	}
+
+	// Validate token periods
+	if config.MaxTokenPeriod == 0 {
+		errs = append(errs, errors.New("invalid maximum token period: must be greater than 0"))
+	}
+	if config.DefaultTokenPeriod == 0 {
+		errs = append(errs, errors.New("invalid default token period: must be greater than 0"))
+	}
+	if config.DefaultTokenPeriod > uint64(config.MaxTokenPeriod) {
+		errs = append(errs, fmt.Errorf("invalid default token period: %d seconds exceed the maximum token period of %d seconds", config.DefaultTokenPeriod, config.MaxTokenPeriod))
+	}
+
+	// Validate server timeouts
+	if config.ServerWriteTimeout != 0 && config.RequestTimeout != 0 && config.ServerWriteTimeout <= config.RequestTimeout {
+		errs = append(errs, fmt.Errorf("invalid server write timeout: %d seconds must exceed the request timeout of %d seconds", config.ServerWriteTimeout, config.RequestTimeout))
+	}
+
+	// Validate port
+	if port, err := strconv.ParseUint(config.Port, 10, 16); err != nil || port == 0 {
+		errs = append(errs, errors.New("invalid port: value '"+config.Port+"' is not a port number between 1 and 65535"))
+	}
+	if config.AdminPort != "" {
+		if port, err := strconv.ParseUint(config.AdminPort, 10, 16); err != nil || port == 0 {
+			errs = append(errs, errors.New("invalid admin port: value '"+config.AdminPort+"' is not a port number between 1 and 65535"))
+		} else if config.AdminPort == config.Port {
+			errs = append(errs, errors.New("invalid admin port: must differ from the port"))
+		}
+	}
+	if config.AdminPort == "" && config.TlsClientAuth == "require" {
+		// The health check cannot present a client certificate
+		errs = append(errs, errors.New("invalid admin port: required if TLS client authentication is 'require'"))
+	}
+
+	return errs
+}
+
+// validateHttpUrl ensures that value is an absolute HTTP or HTTPS URL.
+func validateHttpUrl(value string) error {
+	u, err := url.Parse(value)
+	if err != nil {
+		return errors.New("value '" + value + "' is not a URL")
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return errors.New("value '" + value + "' is not an absolute HTTP or HTTPS URL")
+	}
+	if u.Host == "" {
+		return errors.New("value '" + value + "' has no host")
+	}
+	return nil
+}
+
+// Redacted returns a copy of the configuration without secrets, which is safe to print.
+// Passwords are removed from all URLs, since any of them may contain credentials.
+func (config AppConfiguration) Redacted() AppConfiguration {
+	if config.IntrospectionCredentials != "" {
+		config.IntrospectionCredentials = "REDACTED"
+	}
+	config.UserinfoEndpoint = redactUrl(config.UserinfoEndpoint)
+	config.TokenIntrospectionEndpoint = redactUrl(config.TokenIntrospectionEndpoint)
+	config.JwksUri = redactUrl(config.JwksUri)
+	config.AccessTokenIssuer = redactUrl(config.AccessTokenIssuer)
+	config.HttpProxyUrl = redactUrl(config.HttpProxyUrl)
+	config.EndpointUrl = redactUrl(config.EndpointUrl)
+	config.RedisUrl = redactUrl(config.RedisUrl)
+	config.Issuer = redactUrl(config.Issuer)
+	config.OtlpEndpoint = redactUrl(config.OtlpEndpoint)
+	return config
+}
+
+// redactUrl replaces the password of a URL, or the whole value if it is not a valid URL.
+func redactUrl(value string) string {
+	if value == "" {
+		return ""
+	}
+	parsedUrl, err := url.Parse(value)
+	if err != nil {
+		return "REDACTED"
+	}
+	return parsedUrl.Redacted()
+}
+
+// MarshalJSON encodes the configuration with the signing algorithm as JWA name.
+func (config AppConfiguration) MarshalJSON() ([]byte, error) {
+	type appConfiguration AppConfiguration
+	alg := ""
+	if config.SigningAlgorithm != nil {
+		alg = config.SigningAlgorithm.Alg()
+	}
+	return json.Marshal(struct {
+		appConfiguration
+		SigningAlgorithm string `json:"alg"`
+	}{
+		appConfiguration: appConfiguration(config),
+		SigningAlgorithm: alg,
+	})
 }
@@ -0,0 +1,42 @@
+/*
+ * OIDC² - Identity Certification Token Endpoint
+ *
+ * Endpoint for OpenID Connect's Identity Certification Token endpoint.
+ *
+ * API version: 0.5.0
+ */
+package ict
+
+// Record of an issued Identity Certification Token in the audit log.
+type AuditRecord struct {
+	// Position of the record in the audit log, starting at 1.
+	Sequence uint64 `json:"seq"`
+	// Unix timestamp when the ICT was issued.
+	IssuedAt int64 `json:"iat"`
+	// Unix timestamp when the ICT expires.
+	ExpiresAt int64 `json:"exp"`
+	// Lifetime of the ICT in seconds.
+	Lifetime int64 `json:"lifetime"`
+	// Unique identifier of the ICT.
+	Jti string `json:"jti"`
+	// Subject of the ICT.
+	Subject string `json:"sub"`
+	// Client which requested the ICT.
+	ClientId string `json:"azp,omitempty"`
+	// Granted end-to-end authentication contexts.
+	E2eAuthContexts []string `json:"ctx"`
+	// Names of the identity claims in the ICT, but not their values.
+	Claims []string `json:"claims"`
+	// Base64url encoded SHA-256 JWK thumbprint of the confirmation key 'cnf.jwk'.
+	KeyThumbprint string `json:"jkt"`
+	// Base64url encoded SHA-256 thumbprint of the client certificate 'cnf.x5t#S256', if any.
+	CertificateThumbprint string `json:"x5t#S256,omitempty"`
+	// ID of the key which signed the ICT.
+	SigningKeyId string `json:"kid"`
+	// ID of the request which issued the ICT.
+	RequestId string `json:"request_id,omitempty"`
+	// Hash of the previous record, or the genesis hash for the first record.
+	PreviousHash string `json:"prev_hash"`
+	// Hex encoded SHA-256 hash, or HMAC-SHA256 if an audit HMAC key is configured, of the JSON encoded record without its hash, which includes the previous hash.
+	Hash string `json:"hash,omitempty"`
+}
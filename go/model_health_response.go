@@ -0,0 +1,32 @@
+/*
+ * OIDC² - Identity Certification Token Endpoint
+ *
+ * Endpoint for OpenID Connect's Identity Certification Token endpoint.
+ *
+ * API version: 0.5.0
+ */
+package ict
+
+// Statuses of the endpoint and its dependencies.
+const (
+	HealthStatusUp   = "up"
+	HealthStatusDown = "down"
+)
+
+type HealthResponse struct {
+	// Whether the endpoint is alive or ready: 'up' or 'down'.
+	Status string `json:"status"`
+	// Status of each dependency by name.
+	Checks map[string]HealthCheck `json:"checks,omitempty"`
+}
+
+type HealthCheck struct {
+	// Whether the dependency works: 'up' or 'down'.
+	Status string `json:"status"`
+	// Reason why the dependency does not work.
+	Error string `json:"error,omitempty"`
+	// Unix timestamp when the dependency was checked.
+	CheckedAt int64 `json:"checked_at"`
+	// State of the circuit breaker of the dependency, if any: 'closed', 'open' or 'half-open'.
+	CircuitBreaker string `json:"circuit_breaker,omitempty"`
+}
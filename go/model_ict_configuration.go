@@ -0,0 +1,59 @@
+/*
+ * OIDC² - Identity Certification Token Endpoint
+ *
+ * Endpoint for OpenID Connect's Identity Certification Token endpoint.
+ *
+ * API version: 0.5.0
+ */
+package ict
+
+// Metadata of the Identity Certification Token Endpoint in the style of RFC 8414.
+type IctConfiguration struct {
+	// Issuer of the Identity Certification Tokens.
+	Issuer string `json:"issuer"`
+	// URL to request Identity Certification Tokens from.
+	IctEndpoint string `json:"ict_endpoint"`
+	// URL of the JSON Web Key Set to verify Identity Certification Tokens.
+	JwksUri string `json:"jwks_uri"`
+	// URL to verify Identity Certification Tokens.
+	VerificationEndpoint string `json:"verification_endpoint"`
+	// URL to check whether Identity Certification Tokens are revoked.
+	StatusEndpoint string `json:"status_endpoint"`
+	// URL of the Status List Token which issued Identity Certification Tokens reference in their 'status' claim.
+	StatusListEndpoint string `json:"status_list_endpoint"`
+	// Signing algorithms of issued Identity Certification Tokens.
+	IctSigningAlgValuesSupported []string `json:"ict_signing_alg_values_supported"`
+	// Accepted signing algorithms of Proof of Possession Tokens.
+	PopSigningAlgValuesSupported []string `json:"pop_signing_alg_values_supported"`
+	// Prefix of scopes which grant end-to-end authentication contexts.
+	E2eAuthContextScopePrefix string `json:"e2e_auth_context_scope_prefix"`
+	// Default lifetime of Identity Certification Tokens in seconds.
+	IctDefaultLifetime uint64 `json:"ict_default_lifetime"`
+	// Maximum lifetime of Identity Certification Tokens in seconds.
+	IctMaxLifetime uint32 `json:"ict_max_lifetime"`
+}
+
+func IctConfigurationFromAppConfiguration(config AppConfiguration, endpointUrl string, ictSigningAlgorithms []string) IctConfiguration {
+	// Collect accepted proof of possession algorithms
+	popSigningAlgorithms := []string{}
+	for _, jwa := range SupportedSigningAlgorithms {
+		if _, ok := SigningAlgorithmFromJwa(jwa); ok {
+			popSigningAlgorithms = append(popSigningAlgorithms, jwa)
+		}
+	}
+
+	// Return as struct
+	return IctConfiguration{
+		Issuer:                       config.Issuer,
+		IctEndpoint:                  endpointUrl + "/",
+		JwksUri:                      endpointUrl + "/jwks",
+		VerificationEndpoint:         endpointUrl + "/verify",
+		StatusEndpoint:               endpointUrl + "/status",
+		StatusListEndpoint:           endpointUrl + "/status-list",
+		IctSigningAlgValuesSupported: ictSigningAlgorithms,
+		PopSigningAlgValuesSupported: popSigningAlgorithms,
+		E2eAuthContextScopePrefix:    config.ContextPrefix,
+		IctDefaultLifetime:           config.DefaultTokenPeriod,
+		IctMaxLifetime:               config.MaxTokenPeriod,
+	}
+}
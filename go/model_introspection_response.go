@@ -0,0 +1,97 @@
+/*
+ * OIDC² - Identity Certification Token Endpoint
+ *
+ * Endpoint for OpenID Connect's Identity Certification Token endpoint.
+ *
+ * API version: 0.5.0
+ */
+package ict
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+)
+
+// Token introspection response as described in RFC 7662.
+type IntrospectionResponse struct {
+	// Whether the token is currently active.
+	Active bool `json:"active"`
+	// Space delimited scopes of the token.
+	Scope string `json:"scope,omitempty"`
+	// Client ID of the client which requested the token.
+	ClientId string `json:"client_id,omitempty"`
+	// Authorized party of the token.
+	AuthorizedParty string `json:"azp,omitempty"`
+	// Type of the token, e.g. 'Bearer'.
+	TokenType string `json:"token_type,omitempty"`
+	// Unix timestamp when the token expires.
+	ExpiresAt int64 `json:"exp,omitempty"`
+	// Unix timestamp when the token was issued.
+	IssuedAt int64 `json:"iat,omitempty"`
+	// Unix timestamp before which the token must not be accepted.
+	NotBefore int64 `json:"nbf,omitempty"`
+	// Subject of the token.
+	Subject string `json:"sub,omitempty"`
+	// Audiences of the token.
+	Audience Audience `json:"aud,omitempty"`
+	// Issuer of the token.
+	Issuer string `json:"iss,omitempty"`
+	// Unique identifier of the token.
+	JwtId string `json:"jti,omitempty"`
+}
+
+// Audience claim which is either a single string or an array of strings.
+type Audience []string
+
+func (audience *Audience) UnmarshalJSON(data []byte) error {
+	// Parse single audience
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*audience = Audience{single}
+		return nil
+	}
+
+	// Parse array of audiences
+	var multiple []string
+	if err := json.Unmarshal(data, &multiple); err != nil {
+		return errors.New("audience is neither a string nor an array of strings")
+	}
+	*audience = multiple
+	return nil
+}
+
+// Validate ensures that the introspected token is an active access token at time now.
+func (introspection IntrospectionResponse) Validate(now time.Time) error {
+	if !introspection.Active {
+		return errors.New("access token is not active")
+	}
+	if introspection.ExpiresAt != 0 && now.Unix() >= introspection.ExpiresAt {
+		return errors.New("access token is expired")
+	}
+	if introspection.NotBefore != 0 && now.Unix() < introspection.NotBefore {
+		return errors.New("access token is not valid yet")
+	}
+	if introspection.TokenType != "" && !strings.EqualFold(introspection.TokenType, "Bearer") && introspection.TokenType != "access_token" {
+		return errors.New("token type '" + introspection.TokenType + "' is not an access token")
+	}
+	if introspection.Client() == "" {
+		return errors.New("client ID not present in access token")
+	}
+	return nil
+}
+
+// Client returns the ID of the client which the access token was issued to.
+func (introspection IntrospectionResponse) Client() string {
+	if introspection.AuthorizedParty != "" {
+		return introspection.AuthorizedParty
+	}
+	if introspection.ClientId != "" {
+		return introspection.ClientId
+	}
+	if len(introspection.Audience) == 1 {
+		return introspection.Audience[0]
+	}
+	return ""
+}
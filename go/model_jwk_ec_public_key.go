@@ -8,6 +8,8 @@
 package ict
 
 import (
+	"crypto/ecdsa"
+	"encoding/base64"
 	"errors"
 
 	"github.com/golang-jwt/jwt/v4"
@@ -18,6 +20,32 @@ type JwkEcPublicKey struct {
 	CurveName EcCurve `json:"crv"`
 	X         string  `json:"x"`
 	Y         string  `json:"y"`
+	KeyId     string  `json:"kid,omitempty"`
+	Algorithm string  `json:"alg,omitempty"`
+	Use       string  `json:"use,omitempty"`
+}
+
+func EcJwkFromPublicKey(publicKey *ecdsa.PublicKey) (JwkEcPublicKey, error) {
+	// Get curve name
+	curveName, ok := EcCurveFromName(publicKey.Curve.Params().Name)
+	if !ok {
+		return JwkEcPublicKey{}, errors.New("elliptic curve '" + publicKey.Curve.Params().Name + "' not supported")
+	}
+
+	// Encode coordinates with the curve's full byte length
+	byteLength := (publicKey.Curve.Params().BitSize + 7) / 8
+	x := make([]byte, byteLength)
+	publicKey.X.FillBytes(x)
+	y := make([]byte, byteLength)
+	publicKey.Y.FillBytes(y)
+
+	// Return as struct
+	return JwkEcPublicKey{
+		KeyType:   EC,
+		CurveName: curveName,
+		X:         base64.RawURLEncoding.EncodeToString(x),
+		Y:         base64.RawURLEncoding.EncodeToString(y),
+	}, nil
 }
 
 func EcJwkFromJson(json map[string]interface{}, alg jwt.SigningMethod) (JwkEcPublicKey, error) {
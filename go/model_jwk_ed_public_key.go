@@ -8,6 +8,8 @@
 package ict
 
 import (
+	"crypto/ed25519"
+	"encoding/base64"
 	"errors"
 )
 
@@ -15,6 +17,17 @@ type JwkEdPublicKey struct {
 	KeyType   KeyType `json:"kty"`
 	CurveName EdCurve `json:"crv"`
 	X         string  `json:"x"`
+	KeyId     string  `json:"kid,omitempty"`
+	Algorithm string  `json:"alg,omitempty"`
+	Use       string  `json:"use,omitempty"`
+}
+
+func EdJwkFromPublicKey(publicKey ed25519.PublicKey) JwkEdPublicKey {
+	return JwkEdPublicKey{
+		KeyType:   OKP,
+		CurveName: ED25519,
+		X:         base64.RawURLEncoding.EncodeToString(publicKey),
+	}
 }
 
 func EdJwkFromJson(json map[string]interface{}) (JwkEdPublicKey, error) {
@@ -8,15 +8,94 @@
 package ict
 
 import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
 	"errors"
 
 	"github.com/golang-jwt/jwt/v4"
 )
 
 type JwkPublicKey struct {
-	JwkEcPublicKey
-	JwkRsaPublicKey
-	JwkEdPublicKey
+	JwkEcPublicKey  `json:"-"`
+	JwkRsaPublicKey `json:"-"`
+	JwkEdPublicKey  `json:"-"`
+}
+
+// MarshalJSON encodes the JWK of the key type which is set.
+func (jwk JwkPublicKey) MarshalJSON() ([]byte, error) {
+	switch {
+	case jwk.JwkEcPublicKey.KeyType == EC:
+		return json.Marshal(jwk.JwkEcPublicKey)
+	case jwk.JwkRsaPublicKey.KeyType == RSA:
+		return json.Marshal(jwk.JwkRsaPublicKey)
+	case jwk.JwkEdPublicKey.KeyType == OKP:
+		return json.Marshal(jwk.JwkEdPublicKey)
+	default:
+		return nil, errors.New("failed to encode JWK: key type not set")
+	}
+}
+
+// JwkThumbprint computes the base64url encoded SHA-256 JWK thumbprint of publicKey as defined in RFC 7638.
+func JwkThumbprint(publicKey crypto.PublicKey) (string, error) {
+	// Compose required members in lexicographic order
+	var members string
+	switch publicKey := publicKey.(type) {
+	case *ecdsa.PublicKey:
+		ecJwk, err := EcJwkFromPublicKey(publicKey)
+		if err != nil {
+			return "", err
+		}
+		members = `{"crv":"` + string(ecJwk.CurveName) + `","kty":"EC","x":"` + ecJwk.X + `","y":"` + ecJwk.Y + `"}`
+	case *rsa.PublicKey:
+		rsaJwk := RsaJwkFromPublicKey(publicKey)
+		members = `{"e":"` + rsaJwk.Exponent + `","kty":"RSA","n":"` + rsaJwk.Modulus + `"}`
+	case ed25519.PublicKey:
+		edJwk := EdJwkFromPublicKey(publicKey)
+		members = `{"crv":"Ed25519","kty":"OKP","x":"` + edJwk.X + `"}`
+	default:
+		return "", errors.New("public key type not supported")
+	}
+
+	// Hash and encode
+	hash := sha256.Sum256([]byte(members))
+	return base64.RawURLEncoding.EncodeToString(hash[:]), nil
+}
+
+func PublicJwkFromPublicKey(publicKey crypto.PublicKey, keyId string, alg jwt.SigningMethod) (JwkPublicKey, error) {
+	switch publicKey := publicKey.(type) {
+	// Elliptic Curve:
+	case *ecdsa.PublicKey:
+		ecJwk, err := EcJwkFromPublicKey(publicKey)
+		if err != nil {
+			return JwkPublicKey{}, errors.New("failed to encode EC public key: " + err.Error())
+		}
+		ecJwk.KeyId = keyId
+		ecJwk.Algorithm = alg.Alg()
+		ecJwk.Use = "sig"
+		return JwkPublicKey{JwkEcPublicKey: ecJwk}, nil
+	// RSA:
+	case *rsa.PublicKey:
+		rsaJwk := RsaJwkFromPublicKey(publicKey)
+		rsaJwk.KeyId = keyId
+		rsaJwk.Algorithm = alg.Alg()
+		rsaJwk.Use = "sig"
+		return JwkPublicKey{JwkRsaPublicKey: rsaJwk}, nil
+	// Ed25519:
+	case ed25519.PublicKey:
+		edJwk := EdJwkFromPublicKey(publicKey)
+		edJwk.KeyId = keyId
+		edJwk.Algorithm = alg.Alg()
+		edJwk.Use = "sig"
+		return JwkPublicKey{JwkEdPublicKey: edJwk}, nil
+	// Not supported:
+	default:
+		return JwkPublicKey{}, errors.New("public key type not supported")
+	}
 }
 
 func PublicJwkFromJson(json map[string]interface{}, alg jwt.SigningMethod) (JwkPublicKey, error) {
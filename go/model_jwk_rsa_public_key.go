@@ -8,13 +8,31 @@
 package ict
 
 import (
+	"crypto/rsa"
+	"encoding/base64"
 	"errors"
+	"math/big"
 )
 
 type JwkRsaPublicKey struct {
-	KeyType  KeyType `json:"kty"`
-	Modulus  string  `json:"n"`
-	Exponent string  `json:"e"`
+	KeyType   KeyType `json:"kty"`
+	Modulus   string  `json:"n"`
+	Exponent  string  `json:"e"`
+	KeyId     string  `json:"kid,omitempty"`
+	Algorithm string  `json:"alg,omitempty"`
+	Use       string  `json:"use,omitempty"`
+}
+
+func RsaJwkFromPublicKey(publicKey *rsa.PublicKey) JwkRsaPublicKey {
+	// Encode exponent as unsigned big-endian integer
+	exponent := big.NewInt(int64(publicKey.E)).Bytes()
+
+	// Return as struct
+	return JwkRsaPublicKey{
+		KeyType:  RSA,
+		Modulus:  base64.RawURLEncoding.EncodeToString(publicKey.N.Bytes()),
+		Exponent: base64.RawURLEncoding.EncodeToString(exponent),
+	}
 }
 
 func RsaJwkFromJson(json map[string]interface{}) (JwkRsaPublicKey, error) {
@@ -0,0 +1,35 @@
+/*
+ * OIDC² - Identity Certification Token Endpoint
+ *
+ * Endpoint for OpenID Connect's Identity Certification Token endpoint.
+ *
+ * API version: 0.5.0
+ */
+package ict
+
+import (
+	"errors"
+)
+
+// JSON Web Key Set as defined in RFC 7517, section 5.
+type JwkSet struct {
+	// Public keys to verify Identity Certification Tokens.
+	Keys []JwkPublicKey `json:"keys"`
+}
+
+func JwkSetFromSigningKeys(signingKeys []SigningKey) (JwkSet, error) {
+	keys := make([]JwkPublicKey, 0, len(signingKeys))
+	for _, signingKey := range signingKeys {
+		// Encode public key of signing key
+		jwk, err := PublicJwkFromPublicKey(signingKey.PrivateKey.Public(), signingKey.KeyId, signingKey.Algorithm)
+		if err != nil {
+			return JwkSet{}, errors.New("failed to create JWK set: key '" + signingKey.KeyId + "': " + err.Error())
+		}
+		keys = append(keys, jwk)
+	}
+
+	// Return as set
+	return JwkSet{
+		Keys: keys,
+	}, nil
+}
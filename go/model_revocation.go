@@ -0,0 +1,20 @@
+/*
+ * OIDC² - Identity Certification Token Endpoint
+ *
+ * Endpoint for OpenID Connect's Identity Certification Token endpoint.
+ *
+ * API version: 0.5.0
+ */
+package ict
+
+// Revocation of Identity Certification Tokens by their ID, their subject or their confirmation key.
+type Revocation struct {
+	// Claim which identifies the revoked tokens: 'jti', 'sub' or 'jkt'.
+	Type string `json:"type"`
+	// Revoked ICT ID, subject, or base64url encoded SHA-256 JWK thumbprint of the confirmation key.
+	Value string `json:"value"`
+	// Unix timestamp of the revocation. Revocations by 'sub' or 'jkt' only apply to tokens issued at or before this time.
+	RevokedAt int64 `json:"revoked_at"`
+	// Reason of the revocation.
+	Reason string `json:"reason,omitempty"`
+}
@@ -18,6 +18,9 @@ type SigningAlgorithm struct {
 	RsaSigningAlgorithm
 }
 
+// JWA names of all supported signing algorithms.
+var SupportedSigningAlgorithms = []string{"ES256", "ES384", "ES512", "RS256", "RS384", "RS512", "EdDSA"}
+
 func SigningAlgorithmFromJwa(jwa string) (jwt.SigningMethod, bool) {
 	switch jwa {
 	case "ES256":
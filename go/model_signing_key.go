@@ -0,0 +1,58 @@
+/*
+ * OIDC² - Identity Certification Token Endpoint
+ *
+ * Endpoint for OpenID Connect's Identity Certification Token endpoint.
+ *
+ * API version: 0.5.0
+ */
+package ict
+
+import (
+	"crypto"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+type SigningKeyState string
+
+// List of SigningKeyStates
+const (
+	// Published, but not yet used for signing.
+	NEXT SigningKeyState = "next"
+	// Published and used for signing.
+	ACTIVE SigningKeyState = "active"
+	// Published until all tokens signed with it are expired.
+	RETIRING SigningKeyState = "retiring"
+)
+
+func SigningKeyStateFromString(value string) (SigningKeyState, bool) {
+	switch value {
+	case "next":
+		return NEXT, true
+	case "active":
+		return ACTIVE, true
+	case "retiring":
+		return RETIRING, true
+	default:
+		return "", false
+	}
+}
+
+// Private key to sign Identity Certification Tokens with.
+type SigningKey struct {
+	// Key ID in the JWT header and the JWK set.
+	KeyId string
+	// Signing algorithm of the key.
+	Algorithm jwt.SigningMethod
+	// The private key.
+	PrivateKey crypto.Signer
+	// Rotation state of the key.
+	State SigningKeyState
+	// Time when the key was first published in the JWK set.
+	Published time.Time
+	// Time when the key became active. Zero for next keys.
+	Activated time.Time
+	// Time until a retiring key must stay published. Zero for next and active keys.
+	NotAfter time.Time
+}
@@ -0,0 +1,13 @@
+/*
+ * OIDC² - Identity Certification Token Endpoint
+ *
+ * Endpoint for OpenID Connect's Identity Certification Token endpoint.
+ *
+ * API version: 0.5.0
+ */
+package ict
+
+type StatusRequest struct {
+	// The Identity Certification Token to check.
+	IdentityCertificationToken string `json:"identity_certification_token"`
+}
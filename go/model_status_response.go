@@ -0,0 +1,24 @@
+/*
+ * OIDC² - Identity Certification Token Endpoint
+ *
+ * Endpoint for OpenID Connect's Identity Certification Token endpoint.
+ *
+ * API version: 0.5.0
+ */
+package ict
+
+// Statuses of Identity Certification Tokens.
+const (
+	IctStatusValid   = "valid"
+	IctStatusRevoked = "revoked"
+	IctStatusInvalid = "invalid"
+)
+
+type StatusResponse struct {
+	// Status of the Identity Certification Token: 'valid', 'revoked', or 'invalid', e.g., if expired.
+	Status string `json:"status"`
+	// Reason why the Identity Certification Token is not valid.
+	ErrorDescription string `json:"error_description,omitempty"`
+	// Unix timestamp of the revocation.
+	RevokedAt int64 `json:"revoked_at,omitempty"`
+}
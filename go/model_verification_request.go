@@ -0,0 +1,19 @@
+/*
+ * OIDC² - Identity Certification Token Endpoint
+ *
+ * Endpoint for OpenID Connect's Identity Certification Token endpoint.
+ *
+ * API version: 0.5.0
+ */
+package ict
+
+type VerificationRequest struct {
+	// The Identity Certification Token to verify.
+	IdentityCertificationToken string `json:"identity_certification_token"`
+	// The End-to-End Proof of Possession Token presented with the Identity Certification Token.
+	ProofOfPossessionToken string `json:"proof_of_possession_token"`
+	// Expected audience of the Proof of Possession Token, e.g., the verifying client's ID.
+	Audience string `json:"audience"`
+	// End-to-end authentication contexts which the Identity Certification Token must grant.
+	E2eAuthContexts []string `json:"e2e_auth_contexts,omitempty"`
+}
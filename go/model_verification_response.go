@@ -0,0 +1,23 @@
+/*
+ * OIDC² - Identity Certification Token Endpoint
+ *
+ * Endpoint for OpenID Connect's Identity Certification Token endpoint.
+ *
+ * API version: 0.5.0
+ */
+package ict
+
+type VerificationResponse struct {
+	// Whether both tokens are valid.
+	Valid bool `json:"valid"`
+	// Reason why the tokens are not valid.
+	ErrorDescription string `json:"error_description,omitempty"`
+	// Subject of the Identity Certification Token.
+	Subject string `json:"sub,omitempty"`
+	// Array of authorized end-to-end authentication contexts.
+	E2eAuthContexts []string `json:"e2e_auth_contexts,omitempty"`
+	// Unix timestamp when the Identity Certification Token expires.
+	ExpiresAt int64 `json:"exp,omitempty"`
+	// All claims of the Identity Certification Token.
+	Claims map[string]interface{} `json:"claims,omitempty"`
+}
@@ -0,0 +1,23 @@
+/*
+ * OIDC² - Identity Certification Token Endpoint
+ *
+ * Endpoint for OpenID Connect's Identity Certification Token endpoint.
+ *
+ * API version: 0.5.0
+ */
+package ict
+
+type VersionResponse struct {
+	// Version of the endpoint.
+	Version string `json:"version"`
+	// Version of the REST API.
+	ApiVersion string `json:"api_version"`
+	// Revision of the source code the endpoint was built from.
+	Revision string `json:"revision,omitempty"`
+	// Time of the revision in RFC 3339 format.
+	RevisionTime string `json:"revision_time,omitempty"`
+	// Whether the source code was modified after the revision.
+	Modified bool `json:"modified,omitempty"`
+	// Version of Go the endpoint was built with.
+	GoVersion string `json:"go_version"`
+}
@@ -0,0 +1,130 @@
+/*
+ * OIDC² - Identity Certification Token Endpoint
+ *
+ * Endpoint for OpenID Connect's Identity Certification Token endpoint.
+ *
+ * API version: 0.5.0
+ */
+package ict
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+var appNonceStore NonceStore
+
+// ErrNonceReplayed is returned by NonceStore.Add if the nonce is already stored.
+var ErrNonceReplayed = errors.New("nonce already used")
+
+// NonceStore remembers used nonces of Proof of Possession Tokens until they expire.
+type NonceStore interface {
+	// Add atomically stores nonce until expires if it is not stored yet.
+	// It returns ErrNonceReplayed if the nonce is already stored.
+	Add(ctx context.Context, nonce string, expires time.Time) error
+}
+
+// NonceStoreSizer is implemented by nonce stores which can cheaply count their stored nonces, since they are counted on every metrics scrape.
+type NonceStoreSizer interface {
+	// Size returns the number of stored nonces, including expired ones which are not removed yet.
+	Size(ctx context.Context) (int64, error)
+}
+
+// NonceStorePinger is implemented by nonce stores which depend on a database or server.
+type NonceStorePinger interface {
+	// Ping returns an error if the nonce store cannot be used.
+	Ping(ctx context.Context) error
+}
+
+// SqliteNonceStore stores nonces in the 'nonces' table of a SQLite database.
+type SqliteNonceStore struct {
+	db *sql.DB
+}
+
+func NewSqliteNonceStore(db *sql.DB) *SqliteNonceStore {
+	return &SqliteNonceStore{db: db}
+}
+
+func (store *SqliteNonceStore) Add(ctx context.Context, nonce string, expires time.Time) error {
+	// The primary key on 'nonce' rejects the insertion if the nonce is already stored
+	_, err := store.db.ExecContext(ctx, "INSERT INTO nonces (nonce, expires) VALUES (?, ?)", nonce, expires.UTC())
+	var sqliteErr sqlite3.Error
+	if errors.As(err, &sqliteErr) && (sqliteErr.ExtendedCode == sqlite3.ErrConstraintPrimaryKey || sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique) {
+		return ErrNonceReplayed
+	}
+	if err != nil {
+		return errors.New("failed to insert nonce '" + nonce + "' with expiration date '" + expires.String() + "': " + err.Error())
+	}
+	return nil
+}
+
+func (store *SqliteNonceStore) Size(ctx context.Context) (int64, error) {
+	var size int64
+	if err := store.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM nonces").Scan(&size); err != nil {
+		return 0, errors.New("failed to count nonces: " + err.Error())
+	}
+	return size, nil
+}
+
+func (store *SqliteNonceStore) Ping(ctx context.Context) error {
+	var one int
+	err := store.db.QueryRowContext(ctx, "SELECT 1 FROM nonces LIMIT 1").Scan(&one)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return errors.New("failed to query nonces: " + err.Error())
+	}
+	return nil
+}
+
+// MemoryNonceStore stores nonces in memory of the running instance.
+type MemoryNonceStore struct {
+	mutex   sync.Mutex
+	nonces  map[string]time.Time
+	added   int
+	cleanup int
+}
+
+// NewMemoryNonceStore creates an in-memory nonce store which removes expired nonces after every cleanupInterval insertions.
+func NewMemoryNonceStore(cleanupInterval int) *MemoryNonceStore {
+	return &MemoryNonceStore{
+		nonces:  make(map[string]time.Time),
+		cleanup: cleanupInterval,
+	}
+}
+
+func (store *MemoryNonceStore) Add(ctx context.Context, nonce string, expires time.Time) error {
+	if err := ctx.Err(); err != nil {
+		return errors.New("failed to insert nonce '" + nonce + "': " + err.Error())
+	}
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	now := time.Now()
+
+	// Reject nonces which are stored and not expired yet
+	if storedExpires, ok := store.nonces[nonce]; ok && storedExpires.After(now) {
+		return ErrNonceReplayed
+	}
+	store.nonces[nonce] = expires
+
+	// Remove expired nonces from time to time
+	store.added++
+	if store.added >= store.cleanup {
+		store.added = 0
+		for storedNonce, storedExpires := range store.nonces {
+			if !storedExpires.After(now) {
+				delete(store.nonces, storedNonce)
+			}
+		}
+	}
+	return nil
+}
+
+func (store *MemoryNonceStore) Size(_ context.Context) (int64, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	return int64(len(store.nonces)), nil
+}
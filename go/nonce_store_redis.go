@@ -0,0 +1,69 @@
+/*
+ * OIDC² - Identity Certification Token Endpoint
+ *
+ * Endpoint for OpenID Connect's Identity Certification Token endpoint.
+ *
+ * API version: 0.5.0
+ */
+package ict
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// RedisNonceStore stores nonces in Redis, so they can be shared between multiple instances.
+type RedisNonceStore struct {
+	client *redis.Client
+	prefix string
+}
+
+// NewRedisNonceStore connects to the Redis server at redisUrl, e.g., 'redis://localhost:6379/0'.
+func NewRedisNonceStore(redisUrl string) (*RedisNonceStore, error) {
+	options, err := redis.ParseURL(redisUrl)
+	if err != nil {
+		return nil, errors.New("failed to parse Redis URL: " + err.Error())
+	}
+	client := redis.NewClient(options)
+	if err = client.Ping(context.Background()).Err(); err != nil {
+		client.Close()
+		return nil, errors.New("failed to connect to Redis: " + err.Error())
+	}
+	return &RedisNonceStore{
+		client: client,
+		prefix: "ict:nonce:",
+	}, nil
+}
+
+// Close closes the connection to the Redis server.
+func (store *RedisNonceStore) Close() error {
+	return store.client.Close()
+}
+
+func (store *RedisNonceStore) Ping(ctx context.Context) error {
+	if err := store.client.Ping(ctx).Err(); err != nil {
+		return errors.New("failed to ping Redis: " + err.Error())
+	}
+	return nil
+}
+
+func (store *RedisNonceStore) Add(ctx context.Context, nonce string, expires time.Time) error {
+	// Redis requires a positive expiration time
+	ttl := time.Until(expires)
+	if ttl < time.Millisecond {
+		ttl = time.Millisecond
+	}
+
+	// SET key value NX PX ttl
+	added, err := store.client.SetNX(ctx, store.prefix+nonce, expires.Unix(), ttl).Result()
+	if err != nil {
+		return errors.New("failed to store nonce in Redis: " + err.Error())
+	}
+	if !added {
+		return ErrNonceReplayed
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+/*
+ * OIDC² - Identity Certification Token Endpoint
+ *
+ * Endpoint for OpenID Connect's Identity Certification Token endpoint.
+ *
+ * API version: 0.5.0
+ */
+package ict
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+func newTestRedisNonceStore(t *testing.T) (*RedisNonceStore, *miniredis.Miniredis) {
+	t.Helper()
+	server := miniredis.RunT(t)
+	store, err := NewRedisNonceStore("redis://" + server.Addr() + "/0")
+	if err != nil {
+		t.Fatalf("failed to create Redis nonce store: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+	return store, server
+}
+
+func TestRedisNonceStoreRejectsReplayedNonce(t *testing.T) {
+	store, server := newTestRedisNonceStore(t)
+	ctx := context.Background()
+	expires := time.Now().Add(time.Minute)
+
+	if err := store.Add(ctx, "nonce-1", expires); err != nil {
+		t.Fatalf("first Add() = %v, want nil", err)
+	}
+	if err := store.Add(ctx, "nonce-1", expires); !errors.Is(err, ErrNonceReplayed) {
+		t.Fatalf("second Add() = %v, want %v", err, ErrNonceReplayed)
+	}
+	if err := store.Add(ctx, "nonce-2", expires); err != nil {
+		t.Fatalf("Add() of another nonce = %v, want nil", err)
+	}
+
+	// Nonces are namespaced, so they do not collide with other keys of a shared database
+	if !server.Exists("ict:nonce:nonce-1") {
+		t.Errorf("key 'ict:nonce:nonce-1' not found, keys are %v", server.Keys())
+	}
+}
+
+func TestRedisNonceStoreExpiresNonce(t *testing.T) {
+	store, server := newTestRedisNonceStore(t)
+	ctx := context.Background()
+
+	if err := store.Add(ctx, "nonce", time.Now().Add(time.Minute)); err != nil {
+		t.Fatalf("Add() = %v, want nil", err)
+	}
+	ttl := server.TTL("ict:nonce:nonce")
+	if ttl <= 0 || ttl > time.Minute {
+		t.Fatalf("TTL = %v, want at most %v", ttl, time.Minute)
+	}
+
+	// The nonce is rejected until it expires
+	server.FastForward(ttl - time.Second)
+	if err := store.Add(ctx, "nonce", time.Now().Add(time.Minute)); !errors.Is(err, ErrNonceReplayed) {
+		t.Fatalf("Add() before expiry = %v, want %v", err, ErrNonceReplayed)
+	}
+	server.FastForward(2 * time.Second)
+	if err := store.Add(ctx, "nonce", time.Now().Add(time.Minute)); err != nil {
+		t.Fatalf("Add() after expiry = %v, want nil", err)
+	}
+}
+
+func TestRedisNonceStoreExpiresNonceInThePast(t *testing.T) {
+	store, server := newTestRedisNonceStore(t)
+	ctx := context.Background()
+
+	// Redis requires a positive expiration time, so expired nonces are kept for a millisecond
+	if err := store.Add(ctx, "nonce", time.Now().Add(-time.Minute)); err != nil {
+		t.Fatalf("Add() = %v, want nil", err)
+	}
+	server.FastForward(time.Millisecond)
+	if server.Exists("ict:nonce:nonce") {
+		t.Errorf("expired nonce is still stored")
+	}
+}
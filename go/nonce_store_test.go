@@ -0,0 +1,154 @@
+/*
+ * OIDC² - Identity Certification Token Endpoint
+ *
+ * Endpoint for OpenID Connect's Identity Certification Token endpoint.
+ *
+ * API version: 0.5.0
+ */
+package ict
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// Number of goroutines racing to add the same nonce.
+const nonceRaceGoroutines = 32
+
+// testConcurrentAdd adds the same nonce from many goroutines at once and asserts that exactly one succeeds.
+func testConcurrentAdd(t *testing.T, store NonceStore) {
+	t.Helper()
+	ctx := context.Background()
+	expires := time.Now().Add(time.Minute)
+
+	start := make(chan struct{})
+	results := make(chan error, nonceRaceGoroutines)
+	var wg sync.WaitGroup
+	for i := 0; i < nonceRaceGoroutines; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			results <- store.Add(ctx, "nonce", expires)
+		}()
+	}
+	close(start)
+	wg.Wait()
+	close(results)
+
+	added, replayed := 0, 0
+	for err := range results {
+		switch {
+		case err == nil:
+			added++
+		case errors.Is(err, ErrNonceReplayed):
+			replayed++
+		default:
+			t.Errorf("Add() = %v, want nil or %v", err, ErrNonceReplayed)
+		}
+	}
+	if added != 1 || replayed != nonceRaceGoroutines-1 {
+		t.Errorf("Add() succeeded %d times and was rejected as replay %d times, want 1 and %d", added, replayed, nonceRaceGoroutines-1)
+	}
+}
+
+func TestSqliteNonceStoreConcurrentAdd(t *testing.T) {
+	db, err := loadDatabase(filepath.Join(t.TempDir(), "ict.db"))
+	if err != nil {
+		t.Fatalf("failed to load database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	testConcurrentAdd(t, NewSqliteNonceStore(db))
+}
+
+func TestMemoryNonceStoreConcurrentAdd(t *testing.T) {
+	testConcurrentAdd(t, NewMemoryNonceStore(1024))
+}
+
+// testConcurrentGenIct submits the same proof of possession token from many goroutines at once and asserts that exactly one ICT is issued.
+func testConcurrentGenIct(t *testing.T, store NonceStore) {
+	t.Helper()
+	op := newTestOpenIdProvider(t, "alice")
+	useTestApplication(t, op)
+	appNonceStore = store
+	pop := newTestProofOfPossession(t, "alice", appConfig.Issuer)
+	replaysBefore := testutil.ToFloat64(popValidationFailuresTotal.WithLabelValues(PopFailureReplay))
+
+	start := make(chan struct{})
+	results := make(chan *httptest.ResponseRecorder, nonceRaceGoroutines)
+	var wg sync.WaitGroup
+	for i := 0; i < nonceRaceGoroutines; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			req := httptest.NewRequest("POST", "/", strings.NewReader(pop))
+			req.Header.Set("Authorization", "Bearer access-token")
+			w := httptest.NewRecorder()
+			<-start
+			GenIct(w, req)
+			results <- w
+		}()
+	}
+	close(start)
+	wg.Wait()
+	close(results)
+
+	issued, forbidden := 0, 0
+	for w := range results {
+		switch w.Code {
+		case http.StatusCreated:
+			issued++
+		case http.StatusForbidden:
+			forbidden++
+		default:
+			t.Errorf("GenIct() responded %d: %s, want %d or %d", w.Code, w.Body.String(), http.StatusCreated, http.StatusForbidden)
+		}
+	}
+	if issued != 1 || forbidden != nonceRaceGoroutines-1 {
+		t.Errorf("GenIct() issued %d ICTs and refused %d requests, want 1 and %d", issued, forbidden, nonceRaceGoroutines-1)
+	}
+
+	// All refused requests were rejected as replays
+	if replays := testutil.ToFloat64(popValidationFailuresTotal.WithLabelValues(PopFailureReplay)) - replaysBefore; replays != nonceRaceGoroutines-1 {
+		t.Errorf("GenIct() rejected %v replays, want %d", replays, nonceRaceGoroutines-1)
+	}
+}
+
+func TestSqliteNonceStoreConcurrentGenIct(t *testing.T) {
+	db, err := loadDatabase(filepath.Join(t.TempDir(), "nonces.db"))
+	if err != nil {
+		t.Fatalf("failed to load database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	testConcurrentGenIct(t, NewSqliteNonceStore(db))
+}
+
+func TestMemoryNonceStoreConcurrentGenIct(t *testing.T) {
+	testConcurrentGenIct(t, NewMemoryNonceStore(1024))
+}
+
+func TestMemoryNonceStoreAcceptsExpiredNonce(t *testing.T) {
+	store := NewMemoryNonceStore(1024)
+	ctx := context.Background()
+
+	if err := store.Add(ctx, "nonce", time.Now().Add(-time.Second)); err != nil {
+		t.Fatalf("first Add() = %v, want nil", err)
+	}
+	if err := store.Add(ctx, "nonce", time.Now().Add(time.Minute)); err != nil {
+		t.Fatalf("Add() of expired nonce = %v, want nil", err)
+	}
+	if err := store.Add(ctx, "nonce", time.Now().Add(time.Minute)); !errors.Is(err, ErrNonceReplayed) {
+		t.Fatalf("Add() of stored nonce = %v, want %v", err, ErrNonceReplayed)
+	}
+}
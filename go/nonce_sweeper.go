@@ -0,0 +1,100 @@
+/*
+ * OIDC² - Identity Certification Token Endpoint
+ *
+ * Endpoint for OpenID Connect's Identity Certification Token endpoint.
+ *
+ * API version: 0.5.0
+ */
+package ict
+
+import (
+	"database/sql"
+	"errors"
+	"log/slog"
+	"time"
+)
+
+var appNonceSweeper *NonceSweeper
+
+// NonceSweeper periodically deletes expired nonces from the 'nonces' table of a SQLite database.
+type NonceSweeper struct {
+	db        *sql.DB
+	interval  time.Duration
+	batchSize int
+	stop      chan struct{}
+	done      chan struct{}
+}
+
+func NewNonceSweeper(db *sql.DB, interval time.Duration, batchSize int) *NonceSweeper {
+	return &NonceSweeper{
+		db:        db,
+		interval:  interval,
+		batchSize: batchSize,
+		stop:      make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+}
+
+// Start runs the sweeper in the background until Stop is called.
+func (sweeper *NonceSweeper) Start() {
+	go func() {
+		defer close(sweeper.done)
+		ticker := time.NewTicker(sweeper.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-sweeper.stop:
+				return
+			case <-ticker.C:
+				deleted, err := sweeper.Sweep(time.Now())
+				if err != nil {
+					slog.Error("failed to sweep expired nonces", "error", err)
+				} else if deleted > 0 {
+					slog.Info("deleted expired nonces", "count", deleted)
+				}
+			}
+		}
+	}()
+}
+
+// Stop stops the sweeper and waits until a running sweep is finished.
+func (sweeper *NonceSweeper) Stop() {
+	close(sweeper.stop)
+	<-sweeper.done
+}
+
+// Sweep deletes all nonces expired at now in batches and returns the number of deleted nonces.
+func (sweeper *NonceSweeper) Sweep(now time.Time) (int64, error) {
+	nonceSweepsTotal.Inc()
+	var total int64
+	for {
+		// Stop early on shutdown
+		select {
+		case <-sweeper.stop:
+			return total, nil
+		default:
+		}
+
+		// Delete next batch
+		result, err := sweeper.db.Exec(
+			"DELETE FROM nonces WHERE rowid IN (SELECT rowid FROM nonces WHERE expires <= ? LIMIT ?)",
+			now.UTC(), sweeper.batchSize,
+		)
+		if err != nil {
+			nonceSweepErrorsTotal.Inc()
+			return total, errors.New("failed to delete expired nonces: " + err.Error())
+		}
+		deleted, err := result.RowsAffected()
+		if err != nil {
+			nonceSweepErrorsTotal.Inc()
+			return total, errors.New("failed to count deleted nonces: " + err.Error())
+		}
+		total += deleted
+		noncesSweptTotal.Add(float64(deleted))
+
+		// Finish after the last batch
+		if deleted < int64(sweeper.batchSize) {
+			return total, nil
+		}
+	}
+}
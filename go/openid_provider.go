@@ -0,0 +1,81 @@
+/*
+ * OIDC² - Identity Certification Token Endpoint
+ *
+ * Endpoint for OpenID Connect's Identity Certification Token endpoint.
+ *
+ * API version: 0.5.0
+ */
+package ict
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Results of the concurrent requests to the OpenID Provider for an access token.
+type OpenIdProviderResult struct {
+	// Claims of the userinfo response.
+	UserinfoClaims map[string]interface{}
+	// Error of the userinfo request.
+	UserinfoErr error
+	// Whether the userinfo endpoint rejected the access token.
+	AuthFailed bool
+	// Introspected or locally validated access token.
+	Introspection IntrospectionResponse
+	// Error of the access token validation.
+	IntrospectionErr error
+	// Whether the access token was rejected.
+	TokenRejected bool
+	// Whether the access token validation failed before the userinfo request.
+	IntrospectionFailedFirst bool
+}
+
+// RequestOpenIdProvider requests the userinfo and validates the access token concurrently.
+// If one of both fails, the other is cancelled. Both are cancelled if ctx is cancelled.
+func RequestOpenIdProvider(ctx context.Context, bearerToken string, now time.Time) OpenIdProviderResult {
+	ctx, span := tracer.Start(ctx, "RequestOpenIdProvider")
+	defer span.End()
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var result OpenIdProviderResult
+	var failed sync.Once
+	var wg sync.WaitGroup
+	wg.Add(2)
+
+	// Get identity claims from userinfo endpoint
+	go func() {
+		defer wg.Done()
+		ctx, span := tracer.Start(ctx, "RequestUserinfo")
+		result.UserinfoClaims, result.UserinfoErr, result.AuthFailed = RequestUserinfoCached(ctx, bearerToken, appConfig.UserinfoEndpoint, appConfig.Issuer, now)
+		endSpan(span, result.UserinfoErr)
+		if result.UserinfoErr != nil {
+			failed.Do(cancel)
+		}
+	}()
+
+	// Validate access token locally or introspect it
+	go func() {
+		defer wg.Done()
+		ctx, span := tracer.Start(ctx, "ValidateAccessToken", trace.WithAttributes(attribute.String("ict.access_token_validation", appConfig.AccessTokenValidation)))
+		if appAccessTokenValidator != nil {
+			result.Introspection, result.IntrospectionErr, result.TokenRejected = appAccessTokenValidator.Validate(ctx, bearerToken, now)
+		} else {
+			result.Introspection, result.IntrospectionErr, result.TokenRejected = IntrospectAccessToken(ctx, bearerToken, appConfig.TokenIntrospectionEndpoint, now)
+		}
+		endSpan(span, result.IntrospectionErr)
+		if result.IntrospectionErr != nil {
+			failed.Do(func() {
+				result.IntrospectionFailedFirst = true
+				cancel()
+			})
+		}
+	}()
+
+	wg.Wait()
+	return result
+}
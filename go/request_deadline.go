@@ -0,0 +1,46 @@
+/*
+ * OIDC² - Identity Certification Token Endpoint
+ *
+ * Endpoint for OpenID Connect's Identity Certification Token endpoint.
+ *
+ * API version: 0.5.0
+ */
+package ict
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// RequestDeadline cancels the context of every request after the configured request timeout.
+func RequestDeadline(inner http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if appConfig.RequestTimeout == 0 {
+			inner.ServeHTTP(w, r)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), time.Duration(appConfig.RequestTimeout)*time.Second)
+		defer cancel()
+		inner.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// LogAndSendContextError returns true if the request's context is done.
+// If the request deadline exceeded, it responds with 504 Gateway Timeout.
+// If the client cancelled the request, no response is sent.
+func LogAndSendContextError(w http.ResponseWriter, r *http.Request) bool {
+	err := r.Context().Err()
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		LogAndSendError(w, r, http.StatusGatewayTimeout, "gateway timeout", "request deadline exceeded", "request deadline exceeded: "+err.Error())
+		return true
+	}
+	slog.WarnContext(r.Context(), "request cancelled by client", "error", err)
+	return true
+}
@@ -0,0 +1,250 @@
+/*
+ * OIDC² - Identity Certification Token Endpoint
+ *
+ * Endpoint for OpenID Connect's Identity Certification Token endpoint.
+ *
+ * API version: 0.5.0
+ */
+package ict
+
+import (
+	"context"
+	"crypto"
+	"crypto/ed25519"
+	"database/sql"
+	"errors"
+	"time"
+
+	"ict/verify"
+)
+
+var appRevocationStore RevocationStore
+
+// Types of revocations.
+const (
+	RevocationTypeJti = "jti"
+	RevocationTypeSub = "sub"
+	RevocationTypeJkt = "jkt"
+)
+
+// RevocationStore persists revocations of Identity Certification Tokens.
+type RevocationStore interface {
+	// Revoke stores revocation. Revoking the same value again updates the reason and the time.
+	Revoke(ctx context.Context, revocation Revocation) error
+	// Unrevoke removes the revocation of value, and returns false if value was not revoked.
+	Unrevoke(ctx context.Context, revocationType string, value string) (bool, error)
+	// Check returns the revocation which applies to a token with the ID jti, the subject sub and the confirmation key thumbprint jkt,
+	// issued at the Unix timestamp issuedAt, or nil if the token is not revoked. Empty values are not checked.
+	// Revocations by sub or jkt only apply to tokens issued at or before the revocation, so a revoked subject can be certified again.
+	// An issuedAt of 0 applies all revocations of sub and jkt.
+	Check(ctx context.Context, jti string, sub string, jkt string, issuedAt int64) (*Revocation, error)
+	// List returns all revocations, the latest first.
+	List(ctx context.Context) ([]Revocation, error)
+	// NextStatusListIndex reserves the status list index of a token to be issued. Indices are never reused.
+	NextStatusListIndex(ctx context.Context) (uint64, error)
+	// AddStatusListEntry stores the issued token of a reserved status list index until the token expires.
+	AddStatusListEntry(ctx context.Context, entry StatusListEntry) error
+	// RevokedStatusListIndices returns the size of the status list and the indices of the unexpired tokens at time now which are revoked.
+	RevokedStatusListIndices(ctx context.Context, now time.Time) (uint64, []uint64, error)
+	// Close releases the resources of the store.
+	Close() error
+}
+
+// IsRevocationType returns whether revocationType is a supported type of revocations.
+func IsRevocationType(revocationType string) bool {
+	return revocationType == RevocationTypeJti || revocationType == RevocationTypeSub || revocationType == RevocationTypeJkt
+}
+
+// SqliteRevocationStore stores revocations in the 'revocations' table of a SQLite database.
+// Revocations are read on every check, so revocations of other processes, e.g., of the 'revoke' command, apply immediately.
+type SqliteRevocationStore struct {
+	db      *sql.DB
+	closeDb bool
+}
+
+func NewSqliteRevocationStore(db *sql.DB) *SqliteRevocationStore {
+	return &SqliteRevocationStore{db: db}
+}
+
+// OpenRevocationStore opens the configured revocations, e.g., from the command line.
+// Revocations are stored in Redis if the nonce store is 'redis', and in the database file otherwise.
+// The database is opened without preparing it, so the database of a running server is not modified except for the revocations.
+// The returned store must be closed.
+func OpenRevocationStore(config AppConfiguration) (RevocationStore, error) {
+	if config.NonceStore == "redis" {
+		return NewRedisRevocationStore(config.RedisUrl)
+	}
+	db, err := openDatabase(config.DatabaseFile)
+	if err != nil {
+		return nil, err
+	}
+	store := NewSqliteRevocationStore(db)
+	store.closeDb = true
+	return store, nil
+}
+
+func (store *SqliteRevocationStore) Revoke(ctx context.Context, revocation Revocation) error {
+	if !IsRevocationType(revocation.Type) {
+		return errors.New("revocation type '" + revocation.Type + "' is not supported")
+	}
+	if revocation.Value == "" {
+		return errors.New("revoked " + revocation.Type + " is empty")
+	}
+	_, err := store.db.ExecContext(ctx, "INSERT INTO revocations (type, value, revoked_at, reason) VALUES (?, ?, ?, ?) ON CONFLICT (type, value) DO UPDATE SET revoked_at = excluded.revoked_at, reason = excluded.reason", revocation.Type, revocation.Value, time.Unix(revocation.RevokedAt, 0).UTC(), revocation.Reason)
+	if err != nil {
+		return errors.New("failed to revoke " + revocation.Type + " '" + revocation.Value + "': " + err.Error())
+	}
+	return nil
+}
+
+func (store *SqliteRevocationStore) Unrevoke(ctx context.Context, revocationType string, value string) (bool, error) {
+	result, err := store.db.ExecContext(ctx, "DELETE FROM revocations WHERE type = ? AND value = ?", revocationType, value)
+	if err != nil {
+		return false, errors.New("failed to unrevoke " + revocationType + " '" + value + "': " + err.Error())
+	}
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return false, errors.New("failed to unrevoke " + revocationType + " '" + value + "': " + err.Error())
+	}
+	return deleted > 0, nil
+}
+
+func (store *SqliteRevocationStore) Check(ctx context.Context, jti string, sub string, jkt string, issuedAt int64) (*Revocation, error) {
+	var revocation Revocation
+	var revokedAt time.Time
+	var reason sql.NullString
+	issued := time.Unix(issuedAt, 0).UTC()
+	err := store.db.QueryRowContext(ctx, "SELECT type, value, revoked_at, reason FROM revocations WHERE (type = 'jti' AND value = ?) OR (type = 'sub' AND value = ? AND revoked_at >= ?) OR (type = 'jkt' AND value = ? AND revoked_at >= ?) ORDER BY revoked_at LIMIT 1", jti, sub, issued, jkt, issued).Scan(&revocation.Type, &revocation.Value, &revokedAt, &reason)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.New("failed to check revocations: " + err.Error())
+	}
+	revocation.RevokedAt = revokedAt.Unix()
+	revocation.Reason = reason.String
+	return &revocation, nil
+}
+
+func (store *SqliteRevocationStore) List(ctx context.Context) ([]Revocation, error) {
+	rows, err := store.db.QueryContext(ctx, "SELECT type, value, revoked_at, reason FROM revocations ORDER BY revoked_at DESC")
+	if err != nil {
+		return nil, errors.New("failed to list revocations: " + err.Error())
+	}
+	defer rows.Close()
+
+	revocations := []Revocation{}
+	for rows.Next() {
+		var revocation Revocation
+		var revokedAt time.Time
+		var reason sql.NullString
+		if err := rows.Scan(&revocation.Type, &revocation.Value, &revokedAt, &reason); err != nil {
+			return nil, errors.New("failed to read revocation: " + err.Error())
+		}
+		revocation.RevokedAt = revokedAt.Unix()
+		revocation.Reason = reason.String
+		revocations = append(revocations, revocation)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.New("failed to list revocations: " + err.Error())
+	}
+	return revocations, nil
+}
+
+func (store *SqliteRevocationStore) NextStatusListIndex(ctx context.Context) (uint64, error) {
+	// The index is reserved by an incomplete entry, which is completed when the token is issued
+	result, err := store.db.ExecContext(ctx, "INSERT INTO status_list DEFAULT VALUES")
+	if err != nil {
+		return 0, errors.New("failed to reserve status list index: " + err.Error())
+	}
+	idx, err := result.LastInsertId()
+	if err != nil {
+		return 0, errors.New("failed to reserve status list index: " + err.Error())
+	}
+	return uint64(idx), nil
+}
+
+func (store *SqliteRevocationStore) AddStatusListEntry(ctx context.Context, entry StatusListEntry) error {
+	_, err := store.db.ExecContext(ctx, "UPDATE status_list SET jti = ?, sub = ?, jkt = ?, iat = ?, exp = ? WHERE idx = ?", entry.Jti, entry.Subject, entry.KeyThumbprint, time.Unix(entry.IssuedAt, 0).UTC(), time.Unix(entry.ExpiresAt, 0).UTC(), entry.Index)
+	if err != nil {
+		return errors.New("failed to add status list entry: " + err.Error())
+	}
+	return nil
+}
+
+func (store *SqliteRevocationStore) RevokedStatusListIndices(ctx context.Context, now time.Time) (uint64, []uint64, error) {
+	var maxIndex uint64
+	if err := store.db.QueryRowContext(ctx, "SELECT COALESCE(MAX(idx), 0) FROM status_list").Scan(&maxIndex); err != nil {
+		return 0, nil, errors.New("failed to read status list: " + err.Error())
+	}
+
+	// Same conditions as Check
+	rows, err := store.db.QueryContext(ctx, "SELECT idx FROM status_list s WHERE s.exp > ? AND EXISTS (SELECT 1 FROM revocations r WHERE (r.type = 'jti' AND r.value = s.jti) OR (r.type = 'sub' AND r.value = s.sub AND r.revoked_at >= s.iat) OR (r.type = 'jkt' AND r.value = s.jkt AND r.revoked_at >= s.iat))", now.UTC())
+	if err != nil {
+		return 0, nil, errors.New("failed to read status list: " + err.Error())
+	}
+	defer rows.Close()
+	revoked := []uint64{}
+	for rows.Next() {
+		var idx uint64
+		if err := rows.Scan(&idx); err != nil {
+			return 0, nil, errors.New("failed to read status list entry: " + err.Error())
+		}
+		revoked = append(revoked, idx)
+	}
+	if err := rows.Err(); err != nil {
+		return 0, nil, errors.New("failed to read status list: " + err.Error())
+	}
+	return maxIndex + 1, revoked, nil
+}
+
+func (store *SqliteRevocationStore) Close() error {
+	if !store.closeDb {
+		return nil
+	}
+	return store.db.Close()
+}
+
+// CheckIctRevocation returns the revocation which applies to a verified Identity Certification Token, or nil if it is not revoked.
+// Revocations of its subject or its confirmation key only apply if the token was issued at or before the revocation.
+func CheckIctRevocation(ctx context.Context, verifiedIct *verify.VerifiedIct) (*Revocation, error) {
+	jti, _ := StringFromJson(verifiedIct.Claims, "jti")
+	jkt, err := JwkThumbprintFromJson(verifiedIct.PublicKeyJwk)
+	if err != nil {
+		return nil, errors.New("failed to compute confirmation key thumbprint: " + err.Error())
+	}
+	issuedAt, err := Int64FromJson(verifiedIct.Claims, "iat")
+	if err != nil {
+		return nil, errors.New("failed to read issuance time: " + err.Error())
+	}
+	return appRevocationStore.Check(ctx, jti, verifiedIct.Subject, jkt, issuedAt)
+}
+
+// JwkThumbprintFromJson computes the JWK thumbprint of a public key JWK, which does not need an 'alg' member.
+func JwkThumbprintFromJson(jwk map[string]interface{}) (string, error) {
+	keyType, err := StringFromJson(jwk, "kty")
+	if err != nil {
+		return "", errors.New("key type not found: " + err.Error())
+	}
+
+	// Parse the key to compute the thumbprint of its canonical members
+	var publicKey crypto.PublicKey
+	switch keyType {
+	case "EC":
+		publicKey, _, err = EcdsaPublicKeyFromJson(jwk)
+	case "RSA":
+		publicKey, _, err = RsaPublicKeyFromJson(jwk)
+	case "OKP":
+		var edPublicKey *ed25519.PublicKey
+		edPublicKey, _, err = EdDsaPublicKeyFromJson(jwk)
+		if err == nil {
+			publicKey = *edPublicKey
+		}
+	default:
+		return "", errors.New("key type '" + keyType + "' not supported")
+	}
+	if err != nil {
+		return "", err
+	}
+	return JwkThumbprint(publicKey)
+}
@@ -0,0 +1,209 @@
+/*
+ * OIDC² - Identity Certification Token Endpoint
+ *
+ * Endpoint for OpenID Connect's Identity Certification Token endpoint.
+ *
+ * API version: 0.5.0
+ */
+package ict
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// RedisRevocationStore stores revocations in a Redis hash, so they apply to all instances sharing the Redis server.
+// Fields of the hash are the type and the value of a revocation, e.g., 'sub:alice', and values are the JSON encoded revocations.
+// Entries of the status list are stored in another hash by index, and their expiration times in a sorted set to remove them.
+type RedisRevocationStore struct {
+	client            *redis.Client
+	key               string
+	statusListKey     string
+	statusListExpKey  string
+	statusListNextKey string
+}
+
+// NewRedisRevocationStore connects to the Redis server at redisUrl, e.g., 'redis://localhost:6379/0'.
+func NewRedisRevocationStore(redisUrl string) (*RedisRevocationStore, error) {
+	options, err := redis.ParseURL(redisUrl)
+	if err != nil {
+		return nil, errors.New("failed to parse Redis URL: " + err.Error())
+	}
+	client := redis.NewClient(options)
+	if err = client.Ping(context.Background()).Err(); err != nil {
+		client.Close()
+		return nil, errors.New("failed to connect to Redis: " + err.Error())
+	}
+	return &RedisRevocationStore{
+		client:            client,
+		key:               "ict:revocations",
+		statusListKey:     "ict:status_list",
+		statusListExpKey:  "ict:status_list:exp",
+		statusListNextKey: "ict:status_list:next",
+	}, nil
+}
+
+func (store *RedisRevocationStore) Revoke(ctx context.Context, revocation Revocation) error {
+	if !IsRevocationType(revocation.Type) {
+		return errors.New("revocation type '" + revocation.Type + "' is not supported")
+	}
+	if revocation.Value == "" {
+		return errors.New("revoked " + revocation.Type + " is empty")
+	}
+	data, err := json.Marshal(revocation)
+	if err != nil {
+		return errors.New("failed to encode revocation: " + err.Error())
+	}
+	if err := store.client.HSet(ctx, store.key, revocation.Type+":"+revocation.Value, data).Err(); err != nil {
+		return errors.New("failed to revoke " + revocation.Type + " '" + revocation.Value + "': " + err.Error())
+	}
+	return nil
+}
+
+func (store *RedisRevocationStore) Unrevoke(ctx context.Context, revocationType string, value string) (bool, error) {
+	deleted, err := store.client.HDel(ctx, store.key, revocationType+":"+value).Result()
+	if err != nil {
+		return false, errors.New("failed to unrevoke " + revocationType + " '" + value + "': " + err.Error())
+	}
+	return deleted > 0, nil
+}
+
+func (store *RedisRevocationStore) Check(ctx context.Context, jti string, sub string, jkt string, issuedAt int64) (*Revocation, error) {
+	fields := []string{}
+	for revocationType, value := range map[string]string{RevocationTypeJti: jti, RevocationTypeSub: sub, RevocationTypeJkt: jkt} {
+		if value != "" {
+			fields = append(fields, revocationType+":"+value)
+		}
+	}
+	if len(fields) == 0 {
+		return nil, nil
+	}
+	values, err := store.client.HMGet(ctx, store.key, fields...).Result()
+	if err != nil {
+		return nil, errors.New("failed to check revocations: " + err.Error())
+	}
+
+	// Return the earliest revocation, like the SQLite store
+	var earliest *Revocation
+	for _, value := range values {
+		data, ok := value.(string)
+		if !ok {
+			continue
+		}
+		var revocation Revocation
+		if err := json.Unmarshal([]byte(data), &revocation); err != nil {
+			return nil, errors.New("failed to read revocation: " + err.Error())
+		}
+		if revocation.Type != RevocationTypeJti && revocation.RevokedAt < issuedAt {
+			continue
+		}
+		if earliest == nil || revocation.RevokedAt < earliest.RevokedAt {
+			earliest = &revocation
+		}
+	}
+	return earliest, nil
+}
+
+func (store *RedisRevocationStore) List(ctx context.Context) ([]Revocation, error) {
+	values, err := store.client.HVals(ctx, store.key).Result()
+	if err != nil {
+		return nil, errors.New("failed to list revocations: " + err.Error())
+	}
+	revocations := make([]Revocation, 0, len(values))
+	for _, data := range values {
+		var revocation Revocation
+		if err := json.Unmarshal([]byte(data), &revocation); err != nil {
+			return nil, errors.New("failed to read revocation: " + err.Error())
+		}
+		revocations = append(revocations, revocation)
+	}
+	sort.SliceStable(revocations, func(i, j int) bool {
+		return revocations[i].RevokedAt > revocations[j].RevokedAt
+	})
+	return revocations, nil
+}
+
+func (store *RedisRevocationStore) NextStatusListIndex(ctx context.Context) (uint64, error) {
+	idx, err := store.client.Incr(ctx, store.statusListNextKey).Result()
+	if err != nil {
+		return 0, errors.New("failed to reserve status list index: " + err.Error())
+	}
+	return uint64(idx), nil
+}
+
+func (store *RedisRevocationStore) AddStatusListEntry(ctx context.Context, entry StatusListEntry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return errors.New("failed to encode status list entry: " + err.Error())
+	}
+	field := strconv.FormatUint(entry.Index, 10)
+	_, err = store.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, store.statusListKey, field, data)
+		pipe.ZAdd(ctx, store.statusListExpKey, redis.Z{Score: float64(entry.ExpiresAt), Member: field})
+		return nil
+	})
+	if err != nil {
+		return errors.New("failed to add status list entry: " + err.Error())
+	}
+	return nil
+}
+
+func (store *RedisRevocationStore) RevokedStatusListIndices(ctx context.Context, now time.Time) (uint64, []uint64, error) {
+	// Remove expired entries
+	expired, err := store.client.ZRangeByScore(ctx, store.statusListExpKey, &redis.ZRangeBy{Min: "-inf", Max: strconv.FormatInt(now.Unix(), 10)}).Result()
+	if err != nil {
+		return 0, nil, errors.New("failed to read status list: " + err.Error())
+	}
+	if len(expired) > 0 {
+		_, err = store.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.HDel(ctx, store.statusListKey, expired...)
+			pipe.ZRem(ctx, store.statusListExpKey, expired)
+			return nil
+		})
+		if err != nil {
+			return 0, nil, errors.New("failed to remove expired status list entries: " + err.Error())
+		}
+	}
+
+	maxIndex, err := store.client.Get(ctx, store.statusListNextKey).Uint64()
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return 0, nil, errors.New("failed to read status list: " + err.Error())
+	}
+	values, err := store.client.HVals(ctx, store.statusListKey).Result()
+	if err != nil {
+		return 0, nil, errors.New("failed to read status list: " + err.Error())
+	}
+	revocations, err := store.List(ctx)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	revoked := []uint64{}
+	for _, data := range values {
+		var entry StatusListEntry
+		if err := json.Unmarshal([]byte(data), &entry); err != nil {
+			return 0, nil, errors.New("failed to read status list entry: " + err.Error())
+		}
+		if entry.ExpiresAt <= now.Unix() {
+			continue
+		}
+		for _, revocation := range revocations {
+			if entry.IsRevokedBy(revocation) {
+				revoked = append(revoked, entry.Index)
+				break
+			}
+		}
+	}
+	return maxIndex + 1, revoked, nil
+}
+
+// Close closes the connection to the Redis server.
+func (store *RedisRevocationStore) Close() error {
+	return store.client.Close()
+}
@@ -0,0 +1,73 @@
+/*
+ * OIDC² - Identity Certification Token Endpoint
+ *
+ * Endpoint for OpenID Connect's Identity Certification Token endpoint.
+ *
+ * API version: 0.5.0
+ */
+package ict
+
+import (
+	"context"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+func newTestRedisRevocationStore(t *testing.T, server *miniredis.Miniredis) *RedisRevocationStore {
+	t.Helper()
+	store, err := NewRedisRevocationStore("redis://" + server.Addr() + "/0")
+	if err != nil {
+		t.Fatalf("failed to create Redis revocation store: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+	return store
+}
+
+func TestRedisRevocationStoreSharesRevocations(t *testing.T) {
+	server := miniredis.RunT(t)
+	ctx := context.Background()
+
+	// Revocations of one instance apply to all instances of the same Redis server
+	store := newTestRedisRevocationStore(t, server)
+	otherStore := newTestRedisRevocationStore(t, server)
+	if err := store.Revoke(ctx, Revocation{Type: RevocationTypeSub, Value: "alice", RevokedAt: 200, Reason: "left"}); err != nil {
+		t.Fatalf("Revoke() = %v, want nil", err)
+	}
+	if err := store.Revoke(ctx, Revocation{Type: RevocationTypeJkt, Value: "thumbprint", RevokedAt: 100}); err != nil {
+		t.Fatalf("Revoke() = %v, want nil", err)
+	}
+
+	revocation, err := otherStore.Check(ctx, "", "alice", "thumbprint", 0)
+	if err != nil {
+		t.Fatalf("Check() = %v, want nil", err)
+	}
+	if revocation == nil || revocation.Type != RevocationTypeJkt || revocation.RevokedAt != 100 {
+		t.Errorf("Check() = %+v, want earliest revocation of jkt 'thumbprint'", revocation)
+	}
+	if revocation, err := otherStore.Check(ctx, "alice", "bob", "", 0); err != nil || revocation != nil {
+		t.Errorf("Check() of jti 'alice' and sub 'bob' = %+v, %v, want not revoked", revocation, err)
+	}
+	if revocation, err := otherStore.Check(ctx, "", "alice", "", 201); err != nil || revocation != nil {
+		t.Errorf("Check() of sub 'alice' issued after the revocation = %+v, %v, want not revoked", revocation, err)
+	}
+
+	revocations, err := otherStore.List(ctx)
+	if err != nil {
+		t.Fatalf("List() = %v, want nil", err)
+	}
+	if len(revocations) != 2 || revocations[0].Value != "alice" || revocations[0].Reason != "left" {
+		t.Errorf("List() = %+v, want revocation of sub 'alice' first", revocations)
+	}
+
+	// Unrevoked values are no longer refused
+	if deleted, err := otherStore.Unrevoke(ctx, RevocationTypeJkt, "thumbprint"); err != nil || !deleted {
+		t.Fatalf("Unrevoke() = %v, %v, want true", deleted, err)
+	}
+	if deleted, err := otherStore.Unrevoke(ctx, RevocationTypeJkt, "thumbprint"); err != nil || deleted {
+		t.Fatalf("second Unrevoke() = %v, %v, want false", deleted, err)
+	}
+	if revocation, err := store.Check(ctx, "", "", "thumbprint", 0); err != nil || revocation != nil {
+		t.Errorf("Check() of unrevoked jkt = %+v, %v, want not revoked", revocation, err)
+	}
+}
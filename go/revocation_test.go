@@ -0,0 +1,88 @@
+/*
+ * OIDC² - Identity Certification Token Endpoint
+ *
+ * Endpoint for OpenID Connect's Identity Certification Token endpoint.
+ *
+ * API version: 0.5.0
+ */
+package ict
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestOpenRevocationStoreDoesNotPrepareDatabase(t *testing.T) {
+	config := AppConfiguration{DatabaseFile: filepath.Join(t.TempDir(), "ict.db")}
+	db, err := loadDatabase(config.DatabaseFile)
+	if err != nil {
+		t.Fatalf("failed to load database: %v", err)
+	}
+	defer db.Close()
+	if _, err := db.Exec("INSERT INTO nonces (nonce, expires) VALUES (?, ?)", "expired", time.Now().Add(-time.Hour).UTC()); err != nil {
+		t.Fatalf("failed to store nonce: %v", err)
+	}
+
+	// Commands on a running server's database do not delete its nonces
+	store, err := OpenRevocationStore(config)
+	if err != nil {
+		t.Fatalf("OpenRevocationStore() = %v, want nil", err)
+	}
+	defer store.Close()
+	var count int
+	if err := db.QueryRow("SELECT COUNT(*) FROM nonces").Scan(&count); err != nil || count != 1 {
+		t.Errorf("nonces after OpenRevocationStore() = %d, %v, want the expired nonce kept", count, err)
+	}
+	if _, err := store.List(context.Background()); err != nil {
+		t.Errorf("List() = %v, want nil", err)
+	}
+}
+
+func TestSqliteRevocationStoreAppliesSubjectAndKeyRevocationsToEarlierTokens(t *testing.T) {
+	store := NewSqliteRevocationStore(newTestDatabase(t))
+	ctx := context.Background()
+	revokedAt := time.Unix(1700000000, 0)
+	for _, revocation := range []Revocation{
+		{Type: RevocationTypeSub, Value: "alice", RevokedAt: revokedAt.Unix()},
+		{Type: RevocationTypeJkt, Value: "thumbprint", RevokedAt: revokedAt.Unix()},
+		{Type: RevocationTypeJti, Value: "token", RevokedAt: revokedAt.Unix()},
+	} {
+		if err := store.Revoke(ctx, revocation); err != nil {
+			t.Fatalf("Revoke() = %v, want nil", err)
+		}
+	}
+
+	tests := []struct {
+		name        string
+		jti         string
+		sub         string
+		jkt         string
+		issuedAt    int64
+		wantRevoked string
+	}{
+		{"sub issued before", "", "alice", "", revokedAt.Add(-time.Hour).Unix(), RevocationTypeSub},
+		{"sub issued at revocation", "", "alice", "", revokedAt.Unix(), RevocationTypeSub},
+		{"sub issued after", "", "alice", "", revokedAt.Add(time.Second).Unix(), ""},
+		{"jkt issued before", "", "", "thumbprint", revokedAt.Add(-time.Hour).Unix(), RevocationTypeJkt},
+		{"jkt issued after", "", "", "thumbprint", revokedAt.Add(time.Second).Unix(), ""},
+		{"jkt at issuance", "", "", "thumbprint", 0, RevocationTypeJkt},
+		{"jti issued after", "token", "", "", revokedAt.Add(time.Hour).Unix(), RevocationTypeJti},
+		{"other subject", "", "bob", "", revokedAt.Add(-time.Hour).Unix(), ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			revocation, err := store.Check(ctx, test.jti, test.sub, test.jkt, test.issuedAt)
+			if err != nil {
+				t.Fatalf("Check() = %v, want nil", err)
+			}
+			if test.wantRevoked == "" && revocation != nil {
+				t.Errorf("Check() = %+v, want not revoked", revocation)
+			}
+			if test.wantRevoked != "" && (revocation == nil || revocation.Type != test.wantRevoked) {
+				t.Errorf("Check() = %+v, want revoked by %s", revocation, test.wantRevoked)
+			}
+		})
+	}
+}
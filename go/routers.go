@@ -23,12 +23,39 @@ type Route struct {
 
 type Routes []Route
 
+// NewRouter creates the router of the API. The admin routes and the detailed readiness route are included unless an admin port is configured.
 func NewRouter() *mux.Router {
 	router := mux.NewRouter().StrictSlash(true)
+	addRoutes(router, routes)
+	addRoutes(router, healthRoutes)
+	if appConfig.AdminPort == "" {
+		addRoutes(router, adminRoutes)
+		addRoutes(router, adminReadinessRoutes)
+	} else {
+		addRoutes(router, readinessRoutes)
+	}
+	return router
+}
+
+// NewAdminRouter creates the router of the admin port, which also serves the health routes.
+// Its readiness route includes the status of each dependency.
+func NewAdminRouter() *mux.Router {
+	router := mux.NewRouter().StrictSlash(true)
+	addRoutes(router, adminRoutes)
+	addRoutes(router, healthRoutes)
+	addRoutes(router, adminReadinessRoutes)
+	return router
+}
+
+func addRoutes(router *mux.Router, routes Routes) {
 	for _, route := range routes {
 		var handler http.Handler
 		handler = route.HandlerFunc
+		handler = RequestDeadline(handler)
+		handler = Metrics(handler, route.Name)
 		handler = Logger(handler, route.Name)
+		handler = Tracing(handler, route.Name)
+		handler = RequestId(handler)
 
 		router.
 			Methods(route.Method).
@@ -36,8 +63,6 @@ func NewRouter() *mux.Router {
 			Name(route.Name).
 			Handler(handler)
 	}
-
-	return router
 }
 
 var routes = Routes{
@@ -53,4 +78,86 @@ var routes = Routes{
 		"/",
 		IctOptions,
 	},
+	Route{
+		"GetJwks",
+		strings.ToUpper("Get"),
+		"/jwks",
+		GetJwks,
+	},
+	Route{
+		"GetJwksWellKnown",
+		strings.ToUpper("Get"),
+		"/.well-known/jwks.json",
+		GetJwks,
+	},
+	Route{
+		"GetIctConfiguration",
+		strings.ToUpper("Get"),
+		"/.well-known/ict-configuration",
+		GetIctConfiguration,
+	},
+	Route{
+		"PostVerify",
+		strings.ToUpper("Post"),
+		"/verify",
+		PostVerify,
+	},
+	Route{
+		"PostStatus",
+		strings.ToUpper("Post"),
+		"/status",
+		PostStatus,
+	},
+	Route{
+		"GetStatusList",
+		strings.ToUpper("Get"),
+		"/status-list",
+		GetStatusList,
+	},
+}
+
+// Routes which are served on the port and on the admin port, if configured.
+var healthRoutes = Routes{
+	Route{
+		"GetHealthz",
+		strings.ToUpper("Get"),
+		"/healthz",
+		GetHealthz,
+	},
+	Route{
+		"GetVersion",
+		strings.ToUpper("Get"),
+		"/version",
+		GetVersion,
+	},
+}
+
+// Readiness route of the port if an admin port is configured, which only responds with the overall status.
+var readinessRoutes = Routes{
+	Route{
+		"GetReadyz",
+		strings.ToUpper("Get"),
+		"/readyz",
+		GetReadyz,
+	},
+}
+
+// Readiness route of the admin port, or of the port if no admin port is configured, which also responds with the status of each dependency.
+var adminReadinessRoutes = Routes{
+	Route{
+		"GetReadyz",
+		strings.ToUpper("Get"),
+		"/readyz",
+		GetAdminReadyz,
+	},
+}
+
+// Routes which are served on the admin port, if configured.
+var adminRoutes = Routes{
+	Route{
+		"GetMetrics",
+		strings.ToUpper("Get"),
+		"/metrics",
+		GetMetrics,
+	},
 }
@@ -0,0 +1,64 @@
+/*
+ * OIDC² - Identity Certification Token Endpoint
+ *
+ * Endpoint for OpenID Connect's Identity Certification Token endpoint.
+ *
+ * API version: 0.5.0
+ */
+package ict
+
+import (
+	"crypto/tls"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// TLS configuration of the server, nil if TLS is disabled.
+var appTlsConfig *tls.Config
+
+// NewServer creates the HTTP server for handler with the configured port, timeouts, header limit and TLS configuration.
+func NewServer(handler http.Handler) *http.Server {
+	return &http.Server{
+		Addr:              ":" + appConfig.Port,
+		Handler:           handler,
+		ReadTimeout:       time.Duration(appConfig.ServerReadTimeout) * time.Second,
+		ReadHeaderTimeout: time.Duration(appConfig.ServerReadHeaderTimeout) * time.Second,
+		WriteTimeout:      time.Duration(appConfig.ServerWriteTimeout) * time.Second,
+		IdleTimeout:       time.Duration(appConfig.ServerIdleTimeout) * time.Second,
+		MaxHeaderBytes:    int(appConfig.ServerMaxHeaderBytes),
+		TLSConfig:         appTlsConfig,
+		ErrorLog:          slog.NewLogLogger(slog.Default().Handler(), slog.LevelWarn),
+	}
+}
+
+// NewAdminServer creates the plain HTTP server for the admin handler, or returns nil if no admin port is configured.
+func NewAdminServer(handler http.Handler) *http.Server {
+	if appConfig.AdminPort == "" {
+		return nil
+	}
+	return &http.Server{
+		Addr:              ":" + appConfig.AdminPort,
+		Handler:           handler,
+		ReadTimeout:       time.Duration(appConfig.ServerReadTimeout) * time.Second,
+		ReadHeaderTimeout: time.Duration(appConfig.ServerReadHeaderTimeout) * time.Second,
+		WriteTimeout:      time.Duration(appConfig.ServerWriteTimeout) * time.Second,
+		IdleTimeout:       time.Duration(appConfig.ServerIdleTimeout) * time.Second,
+		MaxHeaderBytes:    int(appConfig.ServerMaxHeaderBytes),
+		ErrorLog:          slog.NewLogLogger(slog.Default().Handler(), slog.LevelWarn),
+	}
+}
+
+// ListenAndServe serves HTTPS if TLS is configured, and HTTP otherwise.
+func ListenAndServe(server *http.Server) error {
+	if server.TLSConfig != nil {
+		// The certificate is provided by the TLS configuration
+		return server.ListenAndServeTLS("", "")
+	}
+	return server.ListenAndServe()
+}
+
+// GetShutdownTimeout returns the period to drain in-flight requests on shutdown.
+func GetShutdownTimeout() time.Duration {
+	return time.Duration(appConfig.ShutdownTimeout) * time.Second
+}
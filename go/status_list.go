@@ -0,0 +1,120 @@
+/*
+ * OIDC² - Identity Certification Token Endpoint
+ *
+ * Endpoint for OpenID Connect's Identity Certification Token endpoint.
+ *
+ * API version: 0.5.0
+ */
+package ict
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// Lifetime of a Status List Token, which verifiers may cache for this duration.
+// Revocations therefore apply to verifiers using the status list with this delay, but immediately at '/verify' and '/status'.
+const statusListTokenLifetime = 60 * time.Second
+
+// Status of a revoked token in the status list, as defined by the OAuth Token Status List draft. Valid tokens have status 0.
+const statusListInvalid = 1
+
+// Issued Identity Certification Token which is referenced by an index of the status list.
+type StatusListEntry struct {
+	// Index of the token in the status list.
+	Index uint64 `json:"idx"`
+	// ID of the token.
+	Jti string `json:"jti"`
+	// Subject of the token.
+	Subject string `json:"sub"`
+	// Base64url encoded SHA-256 JWK thumbprint of the token's confirmation key.
+	KeyThumbprint string `json:"jkt"`
+	// Unix timestamp when the token was issued.
+	IssuedAt int64 `json:"iat"`
+	// Unix timestamp when the token expires.
+	ExpiresAt int64 `json:"exp"`
+}
+
+// IsRevokedBy returns whether revocation applies to the token of the entry, like RevocationStore.Check.
+func (entry StatusListEntry) IsRevokedBy(revocation Revocation) bool {
+	switch revocation.Type {
+	case RevocationTypeJti:
+		return revocation.Value == entry.Jti
+	case RevocationTypeSub:
+		return revocation.Value == entry.Subject && entry.IssuedAt <= revocation.RevokedAt
+	case RevocationTypeJkt:
+		return revocation.Value == entry.KeyThumbprint && entry.IssuedAt <= revocation.RevokedAt
+	default:
+		return false
+	}
+}
+
+// StatusListUri returns the URL of the status list, which issued tokens reference in their 'status' claim.
+// Unlike EndpointUrl, it is never derived from the request, since the claim is signed and the request's headers are controlled by the client.
+func StatusListUri(config AppConfiguration) string {
+	return strings.TrimSuffix(config.EndpointUrl, "/") + "/status-list"
+}
+
+// StatusClaim returns the 'status' claim which references index idx of the status list at uri.
+func StatusClaim(idx uint64, uri string) map[string]interface{} {
+	return map[string]interface{}{
+		"status_list": map[string]interface{}{
+			"idx": idx,
+			"uri": uri,
+		},
+	}
+}
+
+// EncodeStatusList encodes a status list of size tokens with one bit per token, which is set for the revoked indices,
+// and compresses it with ZLIB as the 'lst' member of a Status List Token.
+func EncodeStatusList(size uint64, revoked []uint64) (string, error) {
+	statuses := make([]byte, (size+7)/8)
+	for _, idx := range revoked {
+		if idx >= size {
+			return "", errors.New("revoked index exceeds the status list")
+		}
+		statuses[idx/8] |= statusListInvalid << (idx % 8)
+	}
+
+	var compressed bytes.Buffer
+	writer := zlib.NewWriter(&compressed)
+	if _, err := writer.Write(statuses); err != nil {
+		return "", errors.New("failed to compress status list: " + err.Error())
+	}
+	if err := writer.Close(); err != nil {
+		return "", errors.New("failed to compress status list: " + err.Error())
+	}
+	return base64.RawURLEncoding.EncodeToString(compressed.Bytes()), nil
+}
+
+// GenerateStatusListToken signs the status list of size tokens, of which the indices revoked are revoked, as Status List Token at time now.
+func GenerateStatusListToken(signingKey SigningKey, uri string, size uint64, revoked []uint64, now time.Time) (string, error) {
+	lst, err := EncodeStatusList(size, revoked)
+	if err != nil {
+		return "", err
+	}
+
+	token := jwt.NewWithClaims(signingKey.Algorithm, jwt.MapClaims{
+		"sub": uri,
+		"iat": now.Unix(),
+		"exp": now.Add(statusListTokenLifetime).Unix(),
+		"ttl": int64(statusListTokenLifetime.Seconds()),
+		"status_list": map[string]interface{}{
+			"bits": 1,
+			"lst":  lst,
+		},
+	})
+	token.Header["kid"] = signingKey.KeyId
+	token.Header["typ"] = "statuslist+jwt"
+	statusListToken, err := token.SignedString(signingKey.PrivateKey)
+	if err != nil {
+		return "", errors.New("failed to sign status list token: " + err.Error())
+	}
+	return statusListToken, nil
+}
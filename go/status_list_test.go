@@ -0,0 +1,151 @@
+/*
+ * OIDC² - Identity Certification Token Endpoint
+ *
+ * Endpoint for OpenID Connect's Identity Certification Token endpoint.
+ *
+ * API version: 0.5.0
+ */
+package ict
+
+import (
+	"bytes"
+	"compress/zlib"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// decodeTestStatusList decodes the 'lst' member of a Status List Token.
+func decodeTestStatusList(t *testing.T, lst string) []byte {
+	t.Helper()
+	compressed, err := base64.RawURLEncoding.DecodeString(lst)
+	if err != nil {
+		t.Fatalf("failed to decode status list: %v", err)
+	}
+	reader, err := zlib.NewReader(bytes.NewReader(compressed))
+	if err != nil {
+		t.Fatalf("failed to decompress status list: %v", err)
+	}
+	statuses, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatalf("failed to decompress status list: %v", err)
+	}
+	return statuses
+}
+
+func TestEncodeStatusList(t *testing.T) {
+	lst, err := EncodeStatusList(12, []uint64{0, 3, 9})
+	if err != nil {
+		t.Fatalf("EncodeStatusList() = %v, want nil", err)
+	}
+	// Bits are ordered from the least significant bit of the first byte
+	if statuses := decodeTestStatusList(t, lst); !bytes.Equal(statuses, []byte{0b00001001, 0b00000010}) {
+		t.Errorf("EncodeStatusList() = %08b, want [00001001 00000010]", statuses)
+	}
+	if _, err := EncodeStatusList(8, []uint64{8}); err == nil {
+		t.Errorf("EncodeStatusList() with index out of range = nil, want error")
+	}
+}
+
+func TestGenIctReferencesRevocableStatusListIndex(t *testing.T) {
+	op := newTestOpenIdProvider(t, "alice")
+	useTestApplication(t, op)
+
+	// Issue an ICT
+	req := httptest.NewRequest("POST", "/", strings.NewReader(newTestProofOfPossession(t, "alice", appConfig.Issuer)))
+	req.Header.Set("Authorization", "Bearer access-token")
+	w := httptest.NewRecorder()
+	GenIct(w, req)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("status code = %d, want %d: %s", w.Code, http.StatusCreated, w.Body.String())
+	}
+	var response IctResponse
+	json.NewDecoder(w.Body).Decode(&response)
+	ictClaims := jwt.MapClaims{}
+	if _, _, err := jwt.NewParser().ParseUnverified(response.IdentityCertificationToken, ictClaims); err != nil {
+		t.Fatalf("failed to parse ICT: %v", err)
+	}
+	statusList, _ := ictClaims["status"].(map[string]interface{})["status_list"].(map[string]interface{})
+	idx, _ := statusList["idx"].(float64)
+	if statusList["uri"] != "https://ict.example.com/status-list" {
+		t.Fatalf("status claim = %v, want reference of 'https://ict.example.com/status-list'", ictClaims["status"])
+	}
+
+	// Revoke its subject
+	if err := appRevocationStore.Revoke(context.Background(), Revocation{Type: RevocationTypeSub, Value: "alice", RevokedAt: time.Now().Unix()}); err != nil {
+		t.Fatalf("Revoke() = %v, want nil", err)
+	}
+
+	// The status list marks the ICT as revoked
+	w = httptest.NewRecorder()
+	GetStatusList(w, httptest.NewRequest("GET", "/status-list", nil))
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "application/statuslist+jwt" {
+		t.Fatalf("GetStatusList() = %d with content type '%s', want %d", w.Code, w.Header().Get("Content-Type"), http.StatusOK)
+	}
+	claims := jwt.MapClaims{}
+	token, err := jwt.ParseWithClaims(w.Body.String(), claims, func(token *jwt.Token) (interface{}, error) {
+		return appKeyRing.PublicKey(token.Header["kid"].(string), token.Method)
+	})
+	if err != nil || token.Header["typ"] != "statuslist+jwt" || claims["sub"] != "https://ict.example.com/status-list" {
+		t.Fatalf("status list token = %v, %v, want valid token of 'https://ict.example.com/status-list'", claims, err)
+	}
+	lst, _ := claims["status_list"].(map[string]interface{})["lst"].(string)
+	statuses := decodeTestStatusList(t, lst)
+	if uint64(len(statuses)) <= uint64(idx)/8 || statuses[uint64(idx)/8]>>(uint64(idx)%8)&1 != statusListInvalid {
+		t.Errorf("status of index %v in %08b, want revoked", idx, statuses)
+	}
+}
+
+func TestRedisRevocationStoreStatusList(t *testing.T) {
+	server := miniredis.RunT(t)
+	store := newTestRedisRevocationStore(t, server)
+	ctx := context.Background()
+	now := time.Unix(1700000000, 0)
+
+	// Reserve indices and add the issued tokens
+	entries := []StatusListEntry{
+		{Jti: "token-1", Subject: "alice", KeyThumbprint: "key-1", IssuedAt: now.Add(-time.Hour).Unix(), ExpiresAt: now.Add(time.Hour).Unix()},
+		{Jti: "token-2", Subject: "alice", KeyThumbprint: "key-2", IssuedAt: now.Add(time.Minute).Unix(), ExpiresAt: now.Add(time.Hour).Unix()},
+		{Jti: "token-3", Subject: "bob", KeyThumbprint: "key-3", IssuedAt: now.Add(-2 * time.Hour).Unix(), ExpiresAt: now.Add(-time.Hour).Unix()},
+	}
+	for i := range entries {
+		idx, err := store.NextStatusListIndex(ctx)
+		if err != nil {
+			t.Fatalf("NextStatusListIndex() = %v, want nil", err)
+		}
+		entries[i].Index = idx
+		if err := store.AddStatusListEntry(ctx, entries[i]); err != nil {
+			t.Fatalf("AddStatusListEntry() = %v, want nil", err)
+		}
+	}
+	if err := store.Revoke(ctx, Revocation{Type: RevocationTypeSub, Value: "alice", RevokedAt: now.Unix()}); err != nil {
+		t.Fatalf("Revoke() = %v, want nil", err)
+	}
+	if err := store.Revoke(ctx, Revocation{Type: RevocationTypeSub, Value: "bob", RevokedAt: now.Unix()}); err != nil {
+		t.Fatalf("Revoke() = %v, want nil", err)
+	}
+
+	// Only the unexpired token issued before the revocation is revoked
+	size, revoked, err := store.RevokedStatusListIndices(ctx, now)
+	if err != nil {
+		t.Fatalf("RevokedStatusListIndices() = %v, want nil", err)
+	}
+	if size != entries[2].Index+1 || len(revoked) != 1 || revoked[0] != entries[0].Index {
+		t.Errorf("RevokedStatusListIndices() = %d, %v, want %d, [%d]", size, revoked, entries[2].Index+1, entries[0].Index)
+	}
+
+	// Expired tokens are removed
+	if server.HGet("ict:status_list", strconv.FormatUint(entries[2].Index, 10)) != "" {
+		t.Errorf("expired status list entry not removed")
+	}
+}
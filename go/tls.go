@@ -0,0 +1,173 @@
+/*
+ * OIDC² - Identity Certification Token Endpoint
+ *
+ * Endpoint for OpenID Connect's Identity Certification Token endpoint.
+ *
+ * API version: 0.5.0
+ */
+package ict
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"log/slog"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// Minimum period between two checks whether the certificate files changed.
+const certificateReloadInterval = 10 * time.Second
+
+// CertificateReloader provides the server certificate and reloads it when the certificate or key file changes.
+type CertificateReloader struct {
+	certFile    string
+	keyFile     string
+	mutex       sync.Mutex
+	certificate *tls.Certificate
+	modified    time.Time
+	checkedAt   time.Time
+}
+
+// NewCertificateReloader loads the certificate from the PEM encoded certFile and keyFile.
+func NewCertificateReloader(certFile string, keyFile string) (*CertificateReloader, error) {
+	reloader := &CertificateReloader{
+		certFile: certFile,
+		keyFile:  keyFile,
+	}
+	if err := reloader.reload(time.Now()); err != nil {
+		return nil, err
+	}
+	return reloader, nil
+}
+
+// GetCertificate returns the current certificate and is used as tls.Config.GetCertificate.
+func (reloader *CertificateReloader) GetCertificate(_ *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	reloader.mutex.Lock()
+	defer reloader.mutex.Unlock()
+
+	now := time.Now()
+	if now.Sub(reloader.checkedAt) >= certificateReloadInterval {
+		reloader.checkedAt = now
+		if reloader.filesModified() != reloader.modified {
+			// Keep the previous certificate if the new files are incomplete or invalid
+			if err := reloader.reload(now); err != nil {
+				slog.Error("failed to reload TLS certificate", "error", err)
+			} else {
+				slog.Info("reloaded TLS certificate", "file", reloader.certFile)
+			}
+		}
+	}
+	return reloader.certificate, nil
+}
+
+// filesModified returns the latest modification time of the certificate and key file.
+func (reloader *CertificateReloader) filesModified() time.Time {
+	var modified time.Time
+	for _, file := range []string{reloader.certFile, reloader.keyFile} {
+		if info, err := os.Stat(file); err == nil && info.ModTime().After(modified) {
+			modified = info.ModTime()
+		}
+	}
+	return modified
+}
+
+// reload loads the certificate files. The caller must hold the mutex, unless the reloader is not shared yet.
+func (reloader *CertificateReloader) reload(now time.Time) error {
+	modified := reloader.filesModified()
+	certificate, err := tls.LoadX509KeyPair(reloader.certFile, reloader.keyFile)
+	if err != nil {
+		return errors.New("failed to load TLS certificate: " + err.Error())
+	}
+	reloader.certificate = &certificate
+	reloader.modified = modified
+	reloader.checkedAt = now
+	return nil
+}
+
+// TlsVersionFromString returns the TLS version of '1.2' or '1.3'.
+func TlsVersionFromString(version string) (uint16, bool) {
+	switch version {
+	case "1.2":
+		return tls.VersionTLS12, true
+	case "1.3":
+		return tls.VersionTLS13, true
+	default:
+		return 0, false
+	}
+}
+
+// TlsClientAuthFromString returns the client authentication policy of 'none', 'request' or 'require'.
+func TlsClientAuthFromString(clientAuth string) (tls.ClientAuthType, bool) {
+	switch clientAuth {
+	case "none":
+		return tls.NoClientCert, true
+	case "request":
+		return tls.VerifyClientCertIfGiven, true
+	case "require":
+		return tls.RequireAndVerifyClientCert, true
+	default:
+		return tls.NoClientCert, false
+	}
+}
+
+// NewTlsConfig creates the TLS configuration of the server, or returns nil if TLS is not configured.
+func NewTlsConfig(config AppConfiguration) (*tls.Config, error) {
+	if config.TlsCertFile == "" && config.TlsKeyFile == "" {
+		return nil, nil
+	}
+	if config.TlsCertFile == "" || config.TlsKeyFile == "" {
+		return nil, errors.New("both TLS certificate and key file are required")
+	}
+
+	// Load server certificate
+	reloader, err := NewCertificateReloader(config.TlsCertFile, config.TlsKeyFile)
+	if err != nil {
+		return nil, err
+	}
+	minVersion, ok := TlsVersionFromString(config.TlsMinVersion)
+	if !ok {
+		return nil, errors.New("TLS version '" + config.TlsMinVersion + "' is not supported")
+	}
+	tlsConfig := &tls.Config{
+		MinVersion:     minVersion,
+		GetCertificate: reloader.GetCertificate,
+	}
+
+	// Load client certificate verification
+	clientAuth, ok := TlsClientAuthFromString(config.TlsClientAuth)
+	if !ok {
+		return nil, errors.New("TLS client authentication '" + config.TlsClientAuth + "' is not supported")
+	}
+	if clientAuth != tls.NoClientCert {
+		if config.TlsClientCaFile == "" {
+			return nil, errors.New("TLS client CA file is required for client authentication")
+		}
+		caData, err := os.ReadFile(config.TlsClientCaFile)
+		if err != nil {
+			return nil, errors.New("failed to read TLS client CA file: " + err.Error())
+		}
+		caPool := x509.NewCertPool()
+		if !caPool.AppendCertsFromPEM(caData) {
+			return nil, errors.New("failed to parse TLS client CA file '" + config.TlsClientCaFile + "': no PEM encoded certificate found")
+		}
+		tlsConfig.ClientCAs = caPool
+		tlsConfig.ClientAuth = clientAuth
+	}
+
+	return tlsConfig, nil
+}
+
+// ClientCertificateThumbprint returns the base64url encoded SHA-256 thumbprint of the request's verified client certificate
+// as used in the 'x5t#S256' confirmation method of RFC 8705, or an empty string if no client certificate was presented.
+func ClientCertificateThumbprint(r *http.Request) string {
+	if r.TLS == nil || len(r.TLS.PeerCertificates) == 0 {
+		return ""
+	}
+	thumbprint := sha256.Sum256(r.TLS.PeerCertificates[0].Raw)
+	return base64.RawURLEncoding.EncodeToString(thumbprint[:])
+}
@@ -0,0 +1,160 @@
+/*
+ * OIDC² - Identity Certification Token Endpoint
+ *
+ * Endpoint for OpenID Connect's Identity Certification Token endpoint.
+ *
+ * API version: 0.5.0
+ */
+package ict
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Cache of userinfo responses, nil if disabled.
+// Introspection responses are never cached, so access tokens revoked at the OpenID Provider are rejected immediately.
+var appTokenCache *TokenCache
+
+// Kinds of cached responses.
+const (
+	tokenCacheUserinfo = "userinfo"
+)
+
+// TokenCache is a bounded in-memory cache of responses of the OpenID Provider per access token.
+// Entries are keyed by a hash of the access token and evicted least recently used.
+type TokenCache struct {
+	ttl        time.Duration
+	maxEntries int
+	mutex      sync.Mutex
+	entries    map[string]*list.Element
+	order      *list.List
+}
+
+type tokenCacheEntry struct {
+	key       string
+	value     interface{}
+	expiresAt time.Time
+}
+
+func NewTokenCache(ttl time.Duration, maxEntries int) *TokenCache {
+	return &TokenCache{
+		ttl:        ttl,
+		maxEntries: maxEntries,
+		entries:    map[string]*list.Element{},
+		order:      list.New(),
+	}
+}
+
+func tokenCacheKey(kind string, accessToken string) string {
+	hash := sha256.Sum256([]byte(accessToken))
+	return kind + ":" + hex.EncodeToString(hash[:])
+}
+
+// Get returns the cached response of kind for the access token, if it is not expired at time now.
+func (cache *TokenCache) Get(kind string, accessToken string, now time.Time) (interface{}, bool) {
+	key := tokenCacheKey(kind, accessToken)
+
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+
+	element, ok := cache.entries[key]
+	if !ok {
+		tokenCacheMissesTotal.WithLabelValues(kind).Inc()
+		return nil, false
+	}
+	entry := element.Value.(*tokenCacheEntry)
+	if !now.Before(entry.expiresAt) {
+		cache.order.Remove(element)
+		delete(cache.entries, key)
+		tokenCacheMissesTotal.WithLabelValues(kind).Inc()
+		return nil, false
+	}
+	cache.order.MoveToFront(element)
+	tokenCacheHitsTotal.WithLabelValues(kind).Inc()
+	return entry.value, true
+}
+
+// Set caches the response of kind for the access token.
+// The entry expires after the cache's TTL, but not later than tokenExpiresAt, if not zero.
+func (cache *TokenCache) Set(kind string, accessToken string, value interface{}, tokenExpiresAt time.Time, now time.Time) {
+	expiresAt := now.Add(cache.ttl)
+	if !tokenExpiresAt.IsZero() && tokenExpiresAt.Before(expiresAt) {
+		expiresAt = tokenExpiresAt
+	}
+	if !now.Before(expiresAt) {
+		return
+	}
+	key := tokenCacheKey(kind, accessToken)
+
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+
+	// Update existing entry
+	if element, ok := cache.entries[key]; ok {
+		entry := element.Value.(*tokenCacheEntry)
+		entry.value = value
+		entry.expiresAt = expiresAt
+		cache.order.MoveToFront(element)
+		return
+	}
+
+	// Evict least recently used entries
+	for cache.order.Len() >= cache.maxEntries {
+		oldest := cache.order.Back()
+		cache.order.Remove(oldest)
+		delete(cache.entries, oldest.Value.(*tokenCacheEntry).key)
+	}
+
+	cache.entries[key] = cache.order.PushFront(&tokenCacheEntry{
+		key:       key,
+		value:     value,
+		expiresAt: expiresAt,
+	})
+}
+
+// Len returns the number of cached entries.
+func (cache *TokenCache) Len() int {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+	return cache.order.Len()
+}
+
+// accessTokenExpiresAt returns the expiration time of a JWT access token without verifying it.
+// Returns the zero time if the access token is opaque or has no expiration time.
+func accessTokenExpiresAt(accessToken string) time.Time {
+	claims := jwt.MapClaims{}
+	if _, _, err := jwt.NewParser().ParseUnverified(accessToken, &claims); err != nil {
+		return time.Time{}
+	}
+	expiresAt, err := Int64FromJson(claims, "exp")
+	if err != nil {
+		return time.Time{}
+	}
+	return time.Unix(expiresAt, 0)
+}
+
+// RequestUserinfoCached is like RequestUserinfo, but answers from the token cache, if enabled.
+func RequestUserinfoCached(ctx context.Context, bearerToken string, uri string, issuer string, now time.Time) (map[string]interface{}, error, bool) {
+	if appTokenCache != nil {
+		claims, ok := appTokenCache.Get(tokenCacheUserinfo, bearerToken, now)
+		trace.SpanFromContext(ctx).SetAttributes(attribute.Bool("ict.cache_hit", ok))
+		if ok {
+			return claims.(map[string]interface{}), nil, false
+		}
+	}
+
+	claims, err, authFailed := RequestUserinfo(ctx, bearerToken, uri, issuer)
+	if err == nil && appTokenCache != nil {
+		appTokenCache.Set(tokenCacheUserinfo, bearerToken, claims, accessTokenExpiresAt(bearerToken), now)
+	}
+	return claims, err, authFailed
+}
@@ -0,0 +1,135 @@
+/*
+ * OIDC² - Identity Certification Token Endpoint
+ *
+ * Endpoint for OpenID Connect's Identity Certification Token endpoint.
+ *
+ * API version: 0.5.0
+ */
+package ict
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Default service name of exported spans, which is overridden by OTEL_SERVICE_NAME.
+const tracingServiceName = "ict-endpoint"
+
+// Tracer of all spans of the endpoint.
+// It delegates to the global tracer provider and does not record spans if tracing is disabled.
+var tracer = otel.Tracer("ict")
+
+// Tracer provider which exports spans via OTLP, nil if tracing is disabled.
+var appTracerProvider *sdktrace.TracerProvider
+
+// NewTracerProvider creates a tracer provider which exports sampled spans to the configured OTLP/HTTP endpoint,
+// or returns nil if no OTLP endpoint is configured.
+func NewTracerProvider(config AppConfiguration) (*sdktrace.TracerProvider, error) {
+	if config.OtlpEndpoint == "" {
+		return nil, nil
+	}
+
+	// The URL scheme determines whether the exporter uses TLS
+	exporter, err := otlptracehttp.New(context.Background(), otlptracehttp.WithEndpointURL(config.OtlpEndpoint))
+	if err != nil {
+		return nil, errors.New("failed to create OTLP exporter: " + err.Error())
+	}
+	res, err := resource.New(
+		context.Background(),
+		resource.WithAttributes(semconv.ServiceName(tracingServiceName)),
+		resource.WithFromEnv(),
+	)
+	if err != nil {
+		return nil, errors.New("failed to create tracing resource: " + err.Error())
+	}
+
+	return sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(config.TracingSampleRatio))),
+	), nil
+}
+
+// InitTracing installs the W3C trace context propagator and the tracer provider, if not nil.
+// The propagator is installed in any case to forward the trace context of incoming requests to the OpenID Provider.
+func InitTracing(provider *sdktrace.TracerProvider) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	if provider != nil {
+		otel.SetTracerProvider(provider)
+	}
+}
+
+// endSpan records err on span, if not nil, and ends the span.
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// Tracing starts a server span for each request of a route, which continues the trace context of the request.
+func Tracing(inner http.Handler, name string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+		ctx, span := tracer.Start(ctx, name,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				semconv.HTTPRequestMethodKey.String(r.Method),
+				semconv.URLPath(r.URL.Path),
+			),
+		)
+		defer span.End()
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+
+		inner.ServeHTTP(recorder, r.WithContext(ctx))
+
+		span.SetAttributes(semconv.HTTPResponseStatusCode(recorder.status))
+		if recorder.status >= 500 {
+			span.SetStatus(codes.Error, http.StatusText(recorder.status))
+		}
+	})
+}
+
+// tracingTransport starts a client span for each outgoing request and propagates its trace context in the request headers.
+type tracingTransport struct {
+	base http.RoundTripper
+}
+
+func (transport *tracingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx, span := tracer.Start(req.Context(), "HTTP "+req.Method,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.HTTPRequestMethodKey.String(req.Method),
+			semconv.URLFull(req.URL.Scheme+"://"+req.URL.Host+req.URL.Path),
+			semconv.ServerAddress(req.URL.Hostname()),
+		),
+	)
+	defer span.End()
+
+	// A RoundTripper must not modify the original request
+	req = req.Clone(ctx)
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+
+	res, err := transport.base.RoundTrip(req)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+	span.SetAttributes(semconv.HTTPResponseStatusCode(res.StatusCode))
+	if res.StatusCode >= 500 {
+		span.SetStatus(codes.Error, res.Status)
+	}
+	return res, nil
+}
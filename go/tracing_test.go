@@ -0,0 +1,209 @@
+/*
+ * OIDC² - Identity Certification Token Endpoint
+ *
+ * Endpoint for OpenID Connect's Identity Certification Token endpoint.
+ *
+ * API version: 0.5.0
+ */
+package ict
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// testOpenIdProvider serves the userinfo and token introspection endpoints and records the received traceparent headers.
+type testOpenIdProvider struct {
+	*httptest.Server
+	mutex        sync.Mutex
+	traceparents map[string]string
+}
+
+func newTestOpenIdProvider(t *testing.T, subject string) *testOpenIdProvider {
+	t.Helper()
+	op := &testOpenIdProvider{traceparents: map[string]string{}}
+	op.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		op.mutex.Lock()
+		op.traceparents[r.URL.Path] = r.Header.Get("traceparent")
+		op.mutex.Unlock()
+
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/userinfo":
+			json.NewEncoder(w).Encode(map[string]interface{}{"sub": subject, "name": "Alice"})
+		case "/introspect":
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"active":    true,
+				"sub":       subject,
+				"client_id": "client",
+				"scope":     "openid e2e_ctx_email",
+				"exp":       time.Now().Add(time.Minute).Unix(),
+			})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(op.Close)
+	return op
+}
+
+// Traceparent returns the traceparent header received by the endpoint at path.
+func (op *testOpenIdProvider) Traceparent(path string) string {
+	op.mutex.Lock()
+	defer op.mutex.Unlock()
+	return op.traceparents[path]
+}
+
+// newTestProofOfPossession signs a proof of possession token for the ICT Endpoint with a new key, which is embedded in its header.
+func newTestProofOfPossession(t *testing.T, subject string, audience string) string {
+	t.Helper()
+	key := newTestSigningKey(t)
+	jwk, err := PublicJwkFromPublicKey(key.PrivateKey.Public(), "", key.Algorithm)
+	if err != nil {
+		t.Fatalf("failed to encode proof of possession key: %v", err)
+	}
+	jwkData, _ := json.Marshal(jwk)
+	var jwkJson map[string]interface{}
+	json.Unmarshal(jwkData, &jwkJson)
+
+	now := time.Now()
+	token := jwt.NewWithClaims(key.Algorithm, jwt.MapClaims{
+		"sub": subject,
+		"aud": audience,
+		"iat": now.Unix(),
+		"exp": now.Add(time.Minute).Unix(),
+		"jti": "pop-" + key.KeyId,
+	})
+	token.Header["typ"] = "jwt+pop"
+	token.Header["jwk"] = jwkJson
+	pop, err := token.SignedString(key.PrivateKey)
+	if err != nil {
+		t.Fatalf("failed to sign proof of possession token: %v", err)
+	}
+	return pop
+}
+
+// useTestApplication configures the global state of the application to issue ICTs with the OpenID Provider op.
+func useTestApplication(t *testing.T, op *testOpenIdProvider) {
+	t.Helper()
+	previousConfig, previousClient, previousPolicy := appConfig, appHttpClient, appUpstreamPolicy
+	previousKeyRing, previousNonceStore, previousRevocationStore := appKeyRing, appNonceStore, appRevocationStore
+	t.Cleanup(func() {
+		appConfig, appHttpClient, appUpstreamPolicy = previousConfig, previousClient, previousPolicy
+		appKeyRing, appNonceStore, appRevocationStore = previousKeyRing, previousNonceStore, previousRevocationStore
+	})
+
+	appConfig = AppConfiguration{
+		Issuer:                     "https://ict.example.com",
+		EndpointUrl:                "https://ict.example.com",
+		UserinfoEndpoint:           op.URL + "/userinfo",
+		TokenIntrospectionEndpoint: op.URL + "/introspect",
+		DefaultTokenPeriod:         3600,
+		MaxTokenPeriod:             3600,
+		ContextPrefix:              "e2e_ctx_",
+	}
+	appHttpClient = &http.Client{Transport: &tracingTransport{base: http.DefaultTransport}}
+	appUpstreamPolicy.Retries = 0
+
+	keyRing, err := NewStaticKeyRing(newTestSigningKey(t))
+	if err != nil {
+		t.Fatalf("failed to create key ring: %v", err)
+	}
+	appKeyRing = keyRing
+	appNonceStore = NewMemoryNonceStore(1024)
+	db, err := loadDatabase(filepath.Join(t.TempDir(), "ict.db"))
+	if err != nil {
+		t.Fatalf("failed to load database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	appRevocationStore = NewSqliteRevocationStore(db)
+}
+
+func TestTracingRecordsGenIctSpanTree(t *testing.T) {
+	op := newTestOpenIdProvider(t, "alice")
+	useTestApplication(t, op)
+
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	t.Cleanup(func() { provider.Shutdown(context.Background()) })
+	InitTracing(provider)
+
+	// Request an ICT
+	handler := Tracing(http.HandlerFunc(GenIct), "GenIct")
+	req := httptest.NewRequest("POST", "/", strings.NewReader(newTestProofOfPossession(t, "alice", appConfig.Issuer)))
+	req.Header.Set("Authorization", "Bearer access-token")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("status code = %d, want %d: %s", w.Code, http.StatusCreated, w.Body.String())
+	}
+
+	// Index the recorded spans by name
+	spans := map[string]sdktrace.ReadOnlySpan{}
+	for _, span := range recorder.Ended() {
+		if _, ok := spans[span.Name()]; ok {
+			t.Fatalf("span '%s' recorded more than once", span.Name())
+		}
+		spans[span.Name()] = span
+	}
+	root, ok := spans["GenIct"]
+	if !ok {
+		t.Fatalf("server span 'GenIct' not recorded")
+	}
+	if root.SpanKind() != trace.SpanKindServer || root.Parent().IsValid() {
+		t.Errorf("span 'GenIct' has kind %v and parent %v, want root server span", root.SpanKind(), root.Parent().SpanID())
+	}
+
+	// Verify the parent of every span
+	parents := map[string]string{
+		"ParseProofOfPossession":    "GenIct",
+		"RequestOpenIdProvider":     "GenIct",
+		"RequestUserinfo":           "RequestOpenIdProvider",
+		"HTTP GET":                  "RequestUserinfo",
+		"ValidateAccessToken":       "RequestOpenIdProvider",
+		"HTTP POST":                 "ValidateAccessToken",
+		"ValidateProofOfPossession": "GenIct",
+		"StoreNonce":                "ValidateProofOfPossession",
+		"GenerateIct":               "GenIct",
+		"SignIct":                   "GenerateIct",
+		"AddStatusListEntry":        "GenIct",
+	}
+	for name, parentName := range parents {
+		span, ok := spans[name]
+		if !ok {
+			t.Errorf("span '%s' not recorded", name)
+			continue
+		}
+		parent := spans[parentName]
+		if span.Parent().SpanID() != parent.SpanContext().SpanID() {
+			t.Errorf("span '%s' is not a child of span '%s'", name, parentName)
+		}
+		if span.SpanContext().TraceID() != root.SpanContext().TraceID() {
+			t.Errorf("span '%s' is not in the trace of span 'GenIct'", name)
+		}
+	}
+
+	// Verify that the OpenID Provider received the trace context of the client spans
+	for path, clientSpanName := range map[string]string{"/userinfo": "HTTP GET", "/introspect": "HTTP POST"} {
+		clientSpan, ok := spans[clientSpanName]
+		if !ok {
+			continue
+		}
+		want := "00-" + root.SpanContext().TraceID().String() + "-" + clientSpan.SpanContext().SpanID().String() + "-01"
+		if got := op.Traceparent(path); got != want {
+			t.Errorf("traceparent of request to '%s' = '%s', want '%s'", path, got, want)
+		}
+	}
+}
@@ -0,0 +1,200 @@
+/*
+ * OIDC² - Identity Certification Token Endpoint
+ *
+ * Endpoint for OpenID Connect's Identity Certification Token endpoint.
+ *
+ * API version: 0.5.0
+ */
+package ict
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Retry and circuit breaker policy for requests to the OpenID Provider.
+var appUpstreamPolicy = UpstreamPolicy{
+	Retries:                 2,
+	RetryBaseDelay:          100 * time.Millisecond,
+	BreakerFailureThreshold: 5,
+	BreakerOpenPeriod:       30 * time.Second,
+}
+
+// Circuit breakers by upstream endpoint.
+var (
+	appCircuitBreakers      = map[string]*CircuitBreaker{}
+	appCircuitBreakersMutex sync.Mutex
+)
+
+// UpstreamPolicy configures retries and circuit breakers of requests to the OpenID Provider.
+type UpstreamPolicy struct {
+	// Maximum number of retries of a failed request.
+	Retries int
+	// Delay before the first retry, which doubles with every retry. A random jitter is applied.
+	RetryBaseDelay time.Duration
+	// Number of consecutive failures after which the circuit opens. 0 disables the circuit breaker.
+	BreakerFailureThreshold int
+	// Period for which requests are rejected after the circuit opened.
+	BreakerOpenPeriod time.Duration
+}
+
+// UpstreamUnavailableError indicates that an endpoint of the OpenID Provider is currently unavailable.
+type UpstreamUnavailableError struct {
+	// URL of the unavailable endpoint.
+	Endpoint string
+	// Duration after which the endpoint should be requested again.
+	RetryAfter time.Duration
+	// Reason why the endpoint is unavailable.
+	Reason string
+}
+
+func (err *UpstreamUnavailableError) Error() string {
+	return "endpoint '" + err.Endpoint + "' is unavailable: " + err.Reason
+}
+
+// circuitBreaker returns the circuit breaker of an upstream endpoint.
+func circuitBreaker(endpoint string) *CircuitBreaker {
+	appCircuitBreakersMutex.Lock()
+	defer appCircuitBreakersMutex.Unlock()
+
+	breaker, ok := appCircuitBreakers[endpoint]
+	if !ok {
+		breaker = NewCircuitBreaker(appUpstreamPolicy.BreakerFailureThreshold, appUpstreamPolicy.BreakerOpenPeriod)
+		appCircuitBreakers[endpoint] = breaker
+	}
+	return breaker
+}
+
+// CircuitBreakerStates returns the state of the circuit breaker of every requested upstream endpoint.
+func CircuitBreakerStates() map[string]string {
+	appCircuitBreakersMutex.Lock()
+	defer appCircuitBreakersMutex.Unlock()
+
+	states := map[string]string{}
+	for endpoint, breaker := range appCircuitBreakers {
+		states[endpoint] = breaker.State()
+	}
+	return states
+}
+
+// isTransientStatus returns whether a response status code indicates a temporarily unavailable upstream.
+func isTransientStatus(statusCode int) bool {
+	return statusCode == http.StatusBadGateway || statusCode == http.StatusServiceUnavailable || statusCode == http.StatusGatewayTimeout
+}
+
+// isTransientError returns whether a request error indicates a temporarily unreachable upstream,
+// i.e., a refused, reset or timed out connection.
+// Other errors, e.g., of the TLS certificate verification, DNS resolution or the proxy configuration, are permanent.
+func isTransientError(err error) bool {
+	if errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.ECONNRESET) {
+		return true
+	}
+	var certificateErr *tls.CertificateVerificationError
+	var alertErr tls.AlertError
+	var dnsErr *net.DNSError
+	if errors.As(err, &certificateErr) || errors.As(err, &alertErr) || (errors.As(err, &dnsErr) && dnsErr.IsNotFound) {
+		return false
+	}
+	var timeoutErr net.Error
+	if errors.As(err, &timeoutErr) && timeoutErr.Timeout() {
+		return true
+	}
+	var opErr *net.OpError
+	return errors.As(err, &opErr)
+}
+
+// DoUpstreamRequest sends the request created by newRequest to an endpoint of the OpenID Provider.
+// Requests which fail with a transient connection error or a 502, 503 or 504 response are retried with backoff.
+// Permanent errors are returned immediately and are not counted by the circuit breaker.
+// If the endpoint's circuit is open or all retries failed, an *UpstreamUnavailableError is returned.
+// newRequest is called for every attempt with ctx, so the request body can be sent again.
+// If ctx is cancelled, pending retries are aborted and the failure is not counted by the circuit breaker.
+func DoUpstreamRequest(ctx context.Context, endpoint string, newRequest func(ctx context.Context) (*http.Request, error)) (*http.Response, error) {
+	breaker := circuitBreaker(endpoint)
+
+	reason := ""
+	for attempt := 0; attempt <= appUpstreamPolicy.Retries; attempt++ {
+		// Wait before retry
+		if attempt > 0 {
+			upstreamRetriesTotal.WithLabelValues(redactUrl(endpoint)).Inc()
+			maxDelay := appUpstreamPolicy.RetryBaseDelay << (attempt - 1)
+			if maxDelay > 0 {
+				timer := time.NewTimer(maxDelay/2 + time.Duration(rand.Int63n(int64(maxDelay/2)+1)))
+				select {
+				case <-ctx.Done():
+					timer.Stop()
+					return nil, errors.New("request to '" + endpoint + "' cancelled: " + ctx.Err().Error())
+				case <-timer.C:
+				}
+			}
+		}
+
+		req, err := newRequest(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		// Fail fast if circuit is open
+		if allowed, retryAfter := breaker.Allow(time.Now()); !allowed {
+			upstreamRejectedTotal.WithLabelValues(redactUrl(endpoint)).Inc()
+			if reason == "" {
+				reason = "circuit breaker is open"
+			}
+			return nil, &UpstreamUnavailableError{Endpoint: endpoint, RetryAfter: retryAfter, Reason: reason}
+		}
+
+		res, err := appHttpClient.Do(req)
+		if err != nil && ctx.Err() != nil {
+			breaker.Cancel()
+			return nil, errors.New("request to '" + endpoint + "' cancelled: " + ctx.Err().Error())
+		}
+		if err != nil && !isTransientError(err) {
+			breaker.Cancel()
+			return nil, errors.New("request to '" + endpoint + "' failed: " + err.Error())
+		}
+		if err != nil {
+			breaker.Failure(time.Now())
+			reason = err.Error()
+			continue
+		}
+		if isTransientStatus(res.StatusCode) {
+			io.Copy(io.Discard, res.Body)
+			res.Body.Close()
+			breaker.Failure(time.Now())
+			reason = "status code: " + fmt.Sprint(res.StatusCode) + ", status: '" + res.Status + "'"
+			continue
+		}
+
+		breaker.Success()
+		return res, nil
+	}
+
+	retryAfter := breaker.RetryAfter(time.Now())
+	if retryAfter == 0 {
+		retryAfter = time.Second
+	}
+	return nil, &UpstreamUnavailableError{Endpoint: endpoint, RetryAfter: retryAfter, Reason: reason}
+}
+
+// LogAndSendUpstreamError responds with 503 Service Unavailable and a Retry-After header if err is an *UpstreamUnavailableError,
+// and with 500 Internal Server Error otherwise.
+func LogAndSendUpstreamError(w http.ResponseWriter, r *http.Request, err error, details string) {
+	var unavailable *UpstreamUnavailableError
+	if errors.As(err, &unavailable) {
+		retryAfter := int((unavailable.RetryAfter + time.Second - 1) / time.Second)
+		w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+		LogAndSendError(w, r, http.StatusServiceUnavailable, "service unavailable", "OpenID Provider unavailable", details)
+		return
+	}
+	LogAndSendError(w, r, http.StatusInternalServerError, "internal server error", "unknown internal server error", details)
+}
@@ -8,32 +8,363 @@
 package main
 
 import (
-	"log"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
 	"net/http"
 	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
 
 	ict "ict/go"
 )
 
 func main() {
-	log.Printf("Starting Server...")
+	// Parse subcommand
+	args := os.Args[1:]
+	command := ""
+	if len(args) > 0 && isCommand(args[0]) {
+		command = args[0]
+		args = args[1:]
+	}
+
+	// Parse command line flags
+	flags := flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	configFile := flags.String("config", os.Getenv("CONFIG_FILE"), "path to a YAML or JSON configuration file")
+	subject := flags.String("sub", "", "subject of the audit records to query or of the tokens to revoke")
+	jti := flags.String("jti", "", "jti of the audit record to query or of the token to revoke")
+	jkt := flags.String("jkt", "", "JWK thumbprint of the confirmation key of the tokens to revoke")
+	reason := flags.String("reason", "", "reason of the revocation")
+	head := flags.String("head", "", "hash of a previously verified audit record which must still be in the audit log")
+	flags.Parse(args)
+	if command == "" && isCommand(flags.Arg(0)) {
+		command = flags.Arg(0)
+		flags.Parse(flags.Args()[1:])
+	}
+
+	switch command {
+	case "check-config":
+		os.Exit(checkConfig(*configFile))
+	case "healthcheck":
+		os.Exit(healthcheck(*configFile))
+	case "audit-query":
+		os.Exit(auditQuery(*configFile, *subject, *jti))
+	case "audit-verify":
+		os.Exit(auditVerify(*configFile, *head))
+	case "revoke":
+		os.Exit(revoke(*configFile, *jti, *subject, *jkt, *reason))
+	case "unrevoke":
+		os.Exit(unrevoke(*configFile, *jti, *subject, *jkt))
+	case "revocations":
+		os.Exit(listRevocations(*configFile))
+	}
+
+	slog.Info("starting server")
 
 	// Load configuration
-	log.Printf("Loading configuration...")
-	ict.Initialize()
+	slog.Info("loading configuration")
+	ict.Initialize(*configFile)
 
 	// Load router
 	router := ict.NewRouter()
 
-	// Get port
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8080"
+	// Create server
+	server := ict.NewServer(router)
+
+	// Create admin server, if an admin port is configured
+	adminServer := ict.NewAdminServer(ict.NewAdminRouter())
+
+	slog.Info("configuration loaded")
+
+	// Shut down gracefully on SIGTERM or SIGINT
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, syscall.SIGTERM, os.Interrupt)
+	stopped := make(chan struct{})
+	go func() {
+		sig := <-stop
+		slog.Info("received signal, shutting down", "signal", sig.String())
+
+		// Drain in-flight requests
+		ctx, cancel := context.WithTimeout(context.Background(), ict.GetShutdownTimeout())
+		defer cancel()
+		if err := server.Shutdown(ctx); err != nil {
+			slog.Error("failed to drain requests", "error", err)
+		}
+		if adminServer != nil {
+			if err := adminServer.Shutdown(ctx); err != nil {
+				slog.Error("failed to drain admin requests", "error", err)
+			}
+		}
+		close(stopped)
+	}()
+
+	if adminServer != nil {
+		go func() {
+			slog.Info("serving admin endpoints", "port", ict.GetAppConfiguration().AdminPort)
+			if err := adminServer.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+				ict.Shutdown()
+				slog.Error("failed to serve admin endpoints", "error", err)
+				os.Exit(1)
+			}
+		}()
 	}
 
-	log.Printf("Configuration loaded")
+	slog.Info("running", "port", ict.GetAppConfiguration().Port)
+
+	err := ict.ListenAndServe(server)
+	if !errors.Is(err, http.ErrServerClosed) {
+		ict.Shutdown()
+		slog.Error("failed to serve", "error", err)
+		os.Exit(1)
+	}
+
+	<-stopped
+	ict.Shutdown()
+	slog.Info("server stopped")
+}
 
-	log.Printf("Running on port " + port)
+// checkConfig prints the resolved configuration with secrets redacted and all problems found.
+// It returns the exit code, which is non-zero if the configuration is invalid.
+func checkConfig(configFile string) int {
+	config, err := ict.LoadAppConfiguration(configFile)
 
-	log.Fatal(http.ListenAndServe(":"+port, router))
+	output, marshalErr := json.MarshalIndent(config.Redacted(), "", "  ")
+	if marshalErr != nil {
+		fmt.Fprintln(os.Stderr, "failed to print configuration: "+marshalErr.Error())
+		return 1
+	}
+	fmt.Println(string(output))
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "configuration is invalid:")
+		fmt.Fprintln(os.Stderr, err.Error())
+		return 1
+	}
+	fmt.Fprintln(os.Stderr, "configuration is valid")
+	return 0
+}
+
+// isCommand returns whether name is a subcommand.
+func isCommand(name string) bool {
+	switch name {
+	case "check-config", "healthcheck", "audit-query", "audit-verify", "revoke", "unrevoke", "revocations":
+		return true
+	}
+	return false
+}
+
+// healthcheck requests the readiness endpoint of the server running on this host, e.g., as HEALTHCHECK of the Docker image.
+// The admin port is preferred, if configured. It returns the exit code, which is non-zero if the server is not ready.
+func healthcheck(configFile string) int {
+	// Problems of the configuration are reported by the server and 'check-config'
+	config, _ := ict.LoadAppConfiguration(configFile)
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	readyzUrl := "http://127.0.0.1:" + config.Port + "/readyz"
+	if config.AdminPort != "" {
+		readyzUrl = "http://127.0.0.1:" + config.AdminPort + "/readyz"
+	} else if config.TlsClientAuth == "require" {
+		// The port rejects the handshake without a client certificate
+		fmt.Fprintln(os.Stderr, "failed to request readiness: admin port required if TLS client authentication is 'require'")
+		return 1
+	} else if config.TlsCertFile != "" {
+		// The server certificate is issued for the public hostname, not for the loopback address
+		readyzUrl = "https://127.0.0.1:" + config.Port + "/readyz"
+		client.Transport = &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}
+	}
+
+	res, err := client.Get(readyzUrl)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "failed to request readiness: "+err.Error())
+		return 1
+	}
+	defer res.Body.Close()
+	io.Copy(os.Stdout, res.Body)
+
+	if res.StatusCode != http.StatusOK {
+		fmt.Fprintln(os.Stderr, "server is not ready: status code "+strconv.Itoa(res.StatusCode))
+		return 1
+	}
+	return 0
+}
+
+// auditQuery prints the audit records of subject and with jti as JSON lines.
+// It returns the exit code, which is non-zero if the audit log cannot be read or no record matches.
+func auditQuery(configFile string, subject string, jti string) int {
+	if subject == "" && jti == "" {
+		fmt.Fprintln(os.Stderr, "audit-query requires -sub or -jti")
+		return 2
+	}
+	sink, err := openAuditSink(configFile)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		return 1
+	}
+	defer sink.Close()
+
+	records, err := sink.Query(context.Background(), subject, jti)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "failed to query audit log: "+err.Error())
+		return 1
+	}
+	encoder := json.NewEncoder(os.Stdout)
+	for _, record := range records {
+		encoder.Encode(record)
+	}
+	if len(records) == 0 {
+		fmt.Fprintln(os.Stderr, "no audit records found")
+		return 1
+	}
+	return 0
+}
+
+// auditVerify verifies the hash chain of the audit log, which must contain a record with hash head if not empty,
+// and prints the hash of the latest record to verify against next time.
+// It returns the exit code, which is non-zero if the audit log cannot be read or was tampered with.
+func auditVerify(configFile string, head string) int {
+	sink, err := openAuditSink(configFile)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		return 1
+	}
+	defer sink.Close()
+
+	count, latestHash, err := sink.Verify(context.Background(), head)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "audit log is invalid after "+strconv.Itoa(count)+" valid records:")
+		fmt.Fprintln(os.Stderr, err.Error())
+		return 1
+	}
+	fmt.Fprintln(os.Stderr, "audit log is valid: "+strconv.Itoa(count)+" records")
+	fmt.Fprintln(os.Stdout, latestHash)
+	return 0
+}
+
+// openAuditSink opens the audit log of the configuration.
+func openAuditSink(configFile string) (ict.AuditSink, error) {
+	config, err := ict.LoadAppConfiguration(configFile)
+	if err != nil && config.AuditSink == "" {
+		return nil, errors.New("failed to load configuration: " + err.Error())
+	}
+	sink, err := ict.OpenAuditSink(config)
+	if err != nil {
+		return nil, errors.New("failed to open audit log: " + err.Error())
+	}
+	return sink, nil
+}
+
+// revoke revokes the tokens with jti, of subject, or confirmed by the key with thumbprint jkt.
+// The running server applies the revocation immediately. It returns the exit code.
+func revoke(configFile string, jti string, subject string, jkt string, reason string) int {
+	revocationType, value, ok := revocationTarget(jti, subject, jkt)
+	if !ok {
+		fmt.Fprintln(os.Stderr, "revoke requires exactly one of -jti, -sub or -jkt")
+		return 2
+	}
+	store, err := openRevocationStore(configFile)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		return 1
+	}
+	defer store.Close()
+
+	revocation := ict.Revocation{
+		Type:      revocationType,
+		Value:     value,
+		RevokedAt: time.Now().Unix(),
+		Reason:    reason,
+	}
+	if err := store.Revoke(context.Background(), revocation); err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		return 1
+	}
+	json.NewEncoder(os.Stdout).Encode(revocation)
+	return 0
+}
+
+// unrevoke removes the revocation of jti, subject or jkt. It returns the exit code, which is non-zero if the value was not revoked.
+func unrevoke(configFile string, jti string, subject string, jkt string) int {
+	revocationType, value, ok := revocationTarget(jti, subject, jkt)
+	if !ok {
+		fmt.Fprintln(os.Stderr, "unrevoke requires exactly one of -jti, -sub or -jkt")
+		return 2
+	}
+	store, err := openRevocationStore(configFile)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		return 1
+	}
+	defer store.Close()
+
+	deleted, err := store.Unrevoke(context.Background(), revocationType, value)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		return 1
+	}
+	if !deleted {
+		fmt.Fprintln(os.Stderr, revocationType+" is not revoked")
+		return 1
+	}
+	fmt.Fprintln(os.Stderr, revocationType+" unrevoked")
+	return 0
+}
+
+// listRevocations prints all revocations as JSON lines. It returns the exit code.
+func listRevocations(configFile string) int {
+	store, err := openRevocationStore(configFile)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		return 1
+	}
+	defer store.Close()
+
+	revocations, err := store.List(context.Background())
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		return 1
+	}
+	encoder := json.NewEncoder(os.Stdout)
+	for _, revocation := range revocations {
+		encoder.Encode(revocation)
+	}
+	return 0
+}
+
+// revocationTarget returns the type and value of the revocation, or false unless exactly one value is set.
+func revocationTarget(jti string, subject string, jkt string) (string, string, bool) {
+	revocationType, value := "", ""
+	for _, target := range []struct{ revocationType, value string }{
+		{ict.RevocationTypeJti, jti},
+		{ict.RevocationTypeSub, subject},
+		{ict.RevocationTypeJkt, jkt},
+	} {
+		if target.value == "" {
+			continue
+		}
+		if value != "" {
+			return "", "", false
+		}
+		revocationType, value = target.revocationType, target.value
+	}
+	return revocationType, value, value != ""
+}
+
+// openRevocationStore opens the revocations of the configuration, which are stored in Redis or in the database file.
+func openRevocationStore(configFile string) (ict.RevocationStore, error) {
+	config, err := ict.LoadAppConfiguration(configFile)
+	if err != nil && config.DatabaseFile == "" && config.RedisUrl == "" {
+		return nil, errors.New("failed to load configuration: " + err.Error())
+	}
+	store, err := ict.OpenRevocationStore(config)
+	if err != nil {
+		return nil, errors.New("failed to open revocations: " + err.Error())
+	}
+	return store, nil
 }
@@ -0,0 +1,156 @@
+/*
+ * OIDC² - Identity Certification Token Endpoint
+ *
+ * Endpoint for OpenID Connect's Identity Certification Token endpoint.
+ *
+ * API version: 0.5.0
+ */
+package verify
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"math/big"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// Signing algorithms of Identity Certification Tokens and Proof of Possession Tokens.
+var SupportedSigningAlgorithms = []string{"ES256", "ES384", "ES512", "RS256", "RS384", "RS512", "EdDSA"}
+
+// PublicKeyFromJwk parses the public key of a JWK which is used with signing algorithm alg.
+// The key parsers are shared with the ICT Endpoint, so both accept the same keys.
+func PublicKeyFromJwk(jwk map[string]interface{}, alg jwt.SigningMethod) (crypto.PublicKey, error) {
+	switch alg {
+	// Elliptic Curve:
+	case jwt.SigningMethodES256, jwt.SigningMethodES384, jwt.SigningMethodES512:
+		return EcdsaPublicKeyFromJwk(jwk)
+	// RSA:
+	case jwt.SigningMethodRS256, jwt.SigningMethodRS384, jwt.SigningMethodRS512:
+		return RsaPublicKeyFromJwk(jwk)
+	// Ed25519:
+	case jwt.SigningMethodEdDSA:
+		return EdDsaPublicKeyFromJwk(jwk)
+	// Not supported:
+	default:
+		return nil, errors.New("signing algorithm '" + alg.Alg() + "' not supported")
+	}
+}
+
+// EcdsaPublicKeyFromJwk parses an elliptic curve public key JWK with curve P-256, P-384 or P-521.
+func EcdsaPublicKeyFromJwk(jwk map[string]interface{}) (*ecdsa.PublicKey, error) {
+	if kty, _ := jwk["kty"].(string); kty != "EC" {
+		return nil, errors.New("expected key type 'EC'")
+	}
+	var curve elliptic.Curve
+	switch crv, _ := jwk["crv"].(string); crv {
+	case "P-256":
+		curve = elliptic.P256()
+	case "P-384":
+		curve = elliptic.P384()
+	case "P-521":
+		curve = elliptic.P521()
+	default:
+		return nil, errors.New("curve '" + crv + "' not supported")
+	}
+	x, err := bigIntFromJwk(jwk, "x")
+	if err != nil {
+		return nil, err
+	}
+	y, err := bigIntFromJwk(jwk, "y")
+	if err != nil {
+		return nil, err
+	}
+	if !curve.IsOnCurve(x, y) {
+		return nil, errors.New("point is not on curve")
+	}
+	return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+}
+
+// RsaPublicKeyFromJwk parses an RSA public key JWK.
+func RsaPublicKeyFromJwk(jwk map[string]interface{}) (*rsa.PublicKey, error) {
+	if kty, _ := jwk["kty"].(string); kty != "RSA" {
+		return nil, errors.New("expected key type 'RSA'")
+	}
+	n, err := bigIntFromJwk(jwk, "n")
+	if err != nil {
+		return nil, err
+	}
+	e, err := bigIntFromJwk(jwk, "e")
+	if err != nil {
+		return nil, err
+	}
+	if !e.IsInt64() || e.Int64() < 2 || e.Int64() > 1<<31-1 {
+		return nil, errors.New("invalid exponent")
+	}
+	return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+}
+
+// EdDsaPublicKeyFromJwk parses an Ed25519 public key JWK.
+func EdDsaPublicKeyFromJwk(jwk map[string]interface{}) (ed25519.PublicKey, error) {
+	if kty, _ := jwk["kty"].(string); kty != "OKP" {
+		return nil, errors.New("expected key type 'OKP'")
+	}
+	if crv, _ := jwk["crv"].(string); crv != "Ed25519" {
+		return nil, errors.New("curve '" + crv + "' not supported")
+	}
+	x, err := bytesFromJwk(jwk, "x")
+	if err != nil {
+		return nil, err
+	}
+	if len(x) != ed25519.PublicKeySize {
+		return nil, errors.New("invalid x value length")
+	}
+	return ed25519.PublicKey(x), nil
+}
+
+func bigIntFromJwk(jwk map[string]interface{}, member string) (*big.Int, error) {
+	value, err := bytesFromJwk(jwk, member)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(value), nil
+}
+
+func bytesFromJwk(jwk map[string]interface{}, member string) ([]byte, error) {
+	encoded, ok := jwk[member].(string)
+	if !ok {
+		return nil, errors.New("member '" + member + "' not found")
+	}
+	value, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, errors.New("failed to decode member '" + member + "': " + err.Error())
+	}
+	return value, nil
+}
+
+// stringFromClaims returns the string value of member name.
+func stringFromClaims(claims map[string]interface{}, name string) (string, error) {
+	value, ok := claims[name]
+	if !ok {
+		return "", errors.New("attribute '" + name + "' not found")
+	}
+	valueString, ok := value.(string)
+	if !ok {
+		return "", errors.New("attribute '" + name + "' is not a string")
+	}
+	return valueString, nil
+}
+
+// objectFromClaims returns the JSON object value of member name.
+func objectFromClaims(claims map[string]interface{}, name string) (map[string]interface{}, error) {
+	value, ok := claims[name]
+	if !ok {
+		return nil, errors.New("attribute '" + name + "' not found")
+	}
+	object, ok := value.(map[string]interface{})
+	if !ok {
+		return nil, errors.New("attribute '" + name + "' is not an object")
+	}
+	return object, nil
+}
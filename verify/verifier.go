@@ -0,0 +1,280 @@
+/*
+ * OIDC² - Identity Certification Token Endpoint
+ *
+ * Endpoint for OpenID Connect's Identity Certification Token endpoint.
+ *
+ * API version: 0.5.0
+ */
+// Package verify verifies Identity Certification Tokens and the End-to-End Proof of Possession Tokens presented with them.
+// It only depends on the JWT library, so clients can import it without the ICT Endpoint's dependencies.
+package verify
+
+import (
+	"crypto"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// PublicKeyResolver returns the issuer's public key with ID keyId to verify a token signed with algorithm alg.
+type PublicKeyResolver func(keyId string, alg jwt.SigningMethod) (crypto.PublicKey, error)
+
+// JwksPublicKeyResolver resolves public keys from a decoded JSON Web Key Set, e.g., the response of the '/jwks' endpoint.
+func JwksPublicKeyResolver(jwks map[string]interface{}) PublicKeyResolver {
+	return func(keyId string, alg jwt.SigningMethod) (crypto.PublicKey, error) {
+		keys, ok := jwks["keys"].([]interface{})
+		if !ok {
+			return nil, errors.New("attribute 'keys' not found in JWK set")
+		}
+		for _, key := range keys {
+			jwk, ok := key.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			if kid, err := stringFromClaims(jwk, "kid"); err != nil || kid != keyId {
+				continue
+			}
+			if jwkAlg, err := stringFromClaims(jwk, "alg"); err == nil && jwkAlg != alg.Alg() {
+				return nil, errors.New("key '" + keyId + "' is not used with signing algorithm '" + alg.Alg() + "'")
+			}
+			publicKey, err := PublicKeyFromJwk(jwk, alg)
+			if err != nil {
+				return nil, errors.New("failed to parse key '" + keyId + "': " + err.Error())
+			}
+			return publicKey, nil
+		}
+		return nil, errors.New("key '" + keyId + "' not found")
+	}
+}
+
+// IctVerifier verifies Identity Certification Tokens (ICTs) and the End-to-End Proof of Possession (PoP) Tokens presented with them.
+type IctVerifier struct {
+	// Expected issuer of the ICT.
+	Issuer string
+	// Resolves the issuer's public keys.
+	Keys PublicKeyResolver
+	// Tolerated clock skew.
+	Leeway time.Duration
+	// Rejects replayed PoP Tokens, if set. It must return an error if jti was seen before,
+	// and remember jti at least until expiresAt.
+	ReplayCheck func(jti string, expiresAt time.Time) error
+}
+
+// Options of a verification.
+type IctVerificationOptions struct {
+	// Expected audience of the PoP Token, e.g., the verifying client's ID. Required to verify a PoP Token.
+	Audience string
+	// End-to-end authentication contexts which the ICT must grant.
+	Contexts []string
+	// Time of the verification.
+	Now time.Time
+}
+
+// A successfully verified Identity Certification Token.
+type VerifiedIct struct {
+	// All claims of the ICT.
+	Claims jwt.MapClaims
+	// The ICT's subject.
+	Subject string
+	// The granted end-to-end authentication contexts.
+	Contexts []string
+	// The confirmed public key of the ICT's subject.
+	PublicKey crypto.PublicKey
+	// The confirmed public key as JWK.
+	PublicKeyJwk map[string]interface{}
+	// Expiration time of the ICT.
+	ExpiresAt time.Time
+}
+
+// Verify verifies an ICT and the PoP Token which proves possession of the ICT's confirmed key.
+func (verifier IctVerifier) Verify(ict string, pop string, options IctVerificationOptions) (*VerifiedIct, error) {
+	verifiedIct, err := verifier.VerifyIct(ict, options)
+	if err != nil {
+		return nil, err
+	}
+	_, err = verifier.VerifyProofOfPossession(verifiedIct, pop, options)
+	if err != nil {
+		return nil, err
+	}
+	return verifiedIct, nil
+}
+
+// VerifyIct verifies the ICT's type, signature, issuer, time constraints, confirmation claim and contexts.
+func (verifier IctVerifier) VerifyIct(ict string, options IctVerificationOptions) (*VerifiedIct, error) {
+	if verifier.Keys == nil {
+		return nil, errors.New("no public key resolver configured")
+	}
+
+	// Parse token and verify signature
+	parser := jwt.NewParser(jwt.WithValidMethods(SupportedSigningAlgorithms), jwt.WithoutClaimsValidation())
+	claims := jwt.MapClaims{}
+	token, err := parser.ParseWithClaims(ict, &claims, func(token *jwt.Token) (interface{}, error) {
+		keyId, err := stringFromClaims(token.Header, "kid")
+		if err != nil {
+			return nil, errors.New("key ID not found in header: " + err.Error())
+		}
+		return verifier.Keys(keyId, token.Method)
+	})
+	if err != nil {
+		return nil, errors.New("invalid identity certification token: " + err.Error())
+	}
+
+	// Verify type
+	if typ, err := stringFromClaims(token.Header, "typ"); err != nil || typ != "jwt+ict" {
+		return nil, errors.New("invalid identity certification token: expected type 'jwt+ict'")
+	}
+
+	// Verify issuer
+	if !claims.VerifyIssuer(verifier.Issuer, true) {
+		return nil, errors.New("invalid identity certification token: expected issuer '" + verifier.Issuer + "'")
+	}
+
+	// Verify time constraints
+	if err = verifier.verifyTimeConstraints(claims, options.Now); err != nil {
+		return nil, errors.New("invalid identity certification token: " + err.Error())
+	}
+
+	// Verify subject
+	subject, err := stringFromClaims(claims, "sub")
+	if err != nil {
+		return nil, errors.New("invalid identity certification token: subject not found")
+	}
+
+	// Verify confirmation claim
+	confirmation, err := objectFromClaims(claims, "cnf")
+	if err != nil {
+		return nil, errors.New("invalid identity certification token: confirmation claim not found")
+	}
+	publicKeyJwk, err := objectFromClaims(confirmation, "jwk")
+	if err != nil {
+		return nil, errors.New("invalid identity certification token: confirmed public key not found")
+	}
+
+	// Verify contexts
+	contexts, err := contextsFromClaims(claims)
+	if err != nil {
+		return nil, errors.New("invalid identity certification token: " + err.Error())
+	}
+	for _, required := range options.Contexts {
+		if !containsString(contexts, required) {
+			return nil, errors.New("invalid identity certification token: context '" + required + "' not granted")
+		}
+	}
+
+	return &VerifiedIct{
+		Claims:       claims,
+		Subject:      subject,
+		Contexts:     contexts,
+		PublicKeyJwk: publicKeyJwk,
+		ExpiresAt:    expiresAtFromClaims(claims),
+	}, nil
+}
+
+// VerifyProofOfPossession verifies that the PoP Token is signed with the ICT's confirmed key,
+// has the ICT's subject and the expected audience, is currently valid and has a JWT ID.
+// Replayed JWT IDs are only rejected if a ReplayCheck is configured, otherwise the caller is responsible to reject them.
+func (verifier IctVerifier) VerifyProofOfPossession(ict *VerifiedIct, pop string, options IctVerificationOptions) (jwt.MapClaims, error) {
+	// Parse token and verify signature with confirmed key
+	parser := jwt.NewParser(jwt.WithValidMethods(SupportedSigningAlgorithms), jwt.WithoutClaimsValidation())
+	claims := jwt.MapClaims{}
+	_, err := parser.ParseWithClaims(pop, &claims, func(token *jwt.Token) (interface{}, error) {
+		publicKey, err := PublicKeyFromJwk(ict.PublicKeyJwk, token.Method)
+		if err != nil {
+			return nil, errors.New("failed to read confirmed public key: " + err.Error())
+		}
+		ict.PublicKey = publicKey
+		return publicKey, nil
+	})
+	if err != nil {
+		return nil, errors.New("invalid proof of possession token: " + err.Error())
+	}
+
+	// Verify subject
+	if subject, err := stringFromClaims(claims, "sub"); err != nil || subject != ict.Subject {
+		return nil, errors.New("invalid proof of possession token: subject does not match identity certification token")
+	}
+
+	// Verify audience, which prevents PoP Tokens for other verifiers from being replayed
+	if options.Audience == "" {
+		return nil, errors.New("invalid verification options: expected audience of proof of possession token required")
+	}
+	if !claims.VerifyAudience(options.Audience, true) {
+		return nil, errors.New("invalid proof of possession token: expected audience '" + options.Audience + "'")
+	}
+
+	// Verify time constraints
+	if err = verifier.verifyTimeConstraints(claims, options.Now); err != nil {
+		return nil, errors.New("invalid proof of possession token: " + err.Error())
+	}
+
+	// Verify JWT ID
+	jti, err := stringFromClaims(claims, "jti")
+	if err != nil {
+		return nil, errors.New("invalid proof of possession token: jti claim not found")
+	}
+	if verifier.ReplayCheck != nil {
+		if err = verifier.ReplayCheck(jti, expiresAtFromClaims(claims)); err != nil {
+			return nil, errors.New("invalid proof of possession token: " + err.Error())
+		}
+	}
+
+	return claims, nil
+}
+
+func (verifier IctVerifier) verifyTimeConstraints(claims jwt.MapClaims, now time.Time) error {
+	if now.IsZero() {
+		now = time.Now()
+	}
+	leeway := int64(verifier.Leeway / time.Second)
+	nowUnix := now.Unix()
+	if !claims.VerifyExpiresAt(nowUnix-leeway, true) {
+		return errors.New("token expired")
+	}
+	if !claims.VerifyNotBefore(nowUnix+leeway, false) || !claims.VerifyIssuedAt(nowUnix+leeway, true) {
+		return errors.New("token is not yet valid")
+	}
+	return nil
+}
+
+// expiresAtFromClaims returns the time of the verified 'exp' claim.
+func expiresAtFromClaims(claims jwt.MapClaims) time.Time {
+	var expiresAt float64
+	switch exp := claims["exp"].(type) {
+	case float64:
+		expiresAt = exp
+	case json.Number:
+		expiresAt, _ = exp.Float64()
+	}
+	return time.Unix(int64(expiresAt), 0)
+}
+
+func contextsFromClaims(claims jwt.MapClaims) ([]string, error) {
+	ctx, ok := claims["ctx"]
+	if !ok || ctx == nil {
+		return []string{}, nil
+	}
+	values, ok := ctx.([]interface{})
+	if !ok {
+		return nil, errors.New("context claim is not an array")
+	}
+	contexts := make([]string, 0, len(values))
+	for _, value := range values {
+		context, ok := value.(string)
+		if !ok {
+			return nil, errors.New("context claim contains a non-string value")
+		}
+		contexts = append(contexts, context)
+	}
+	return contexts, nil
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,195 @@
+/*
+ * OIDC² - Identity Certification Token Endpoint
+ *
+ * Endpoint for OpenID Connect's Identity Certification Token endpoint.
+ *
+ * API version: 0.5.0
+ */
+package verify
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+const (
+	testIssuer   = "https://op.example.com"
+	testKeyId    = "issuer-key"
+	testAudience = "bob"
+)
+
+func newTestKey(t *testing.T) *ecdsa.PrivateKey {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	return key
+}
+
+func testJwk(key *ecdsa.PrivateKey) map[string]interface{} {
+	return map[string]interface{}{
+		"kty": "EC",
+		"crv": "P-256",
+		"x":   base64.RawURLEncoding.EncodeToString(key.X.FillBytes(make([]byte, 32))),
+		"y":   base64.RawURLEncoding.EncodeToString(key.Y.FillBytes(make([]byte, 32))),
+	}
+}
+
+func signTestToken(t *testing.T, key *ecdsa.PrivateKey, header map[string]interface{}, claims jwt.MapClaims) string {
+	t.Helper()
+	token := jwt.NewWithClaims(jwt.SigningMethodES256, claims)
+	for name, value := range header {
+		token.Header[name] = value
+	}
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatalf("failed to sign token: %v", err)
+	}
+	return signed
+}
+
+// testTokens holds the keys and the claims of an ICT and a PoP Token, which are valid unless modified by a test case.
+type testTokens struct {
+	issuerKey *ecdsa.PrivateKey
+	clientKey *ecdsa.PrivateKey
+	ictHeader map[string]interface{}
+	ictClaims jwt.MapClaims
+	popClaims jwt.MapClaims
+}
+
+func newTestTokens(t *testing.T, now time.Time) *testTokens {
+	t.Helper()
+	clientKey := newTestKey(t)
+	return &testTokens{
+		issuerKey: newTestKey(t),
+		clientKey: clientKey,
+		ictHeader: map[string]interface{}{"typ": "jwt+ict", "kid": testKeyId},
+		ictClaims: jwt.MapClaims{
+			"iss": testIssuer,
+			"sub": "alice",
+			"iat": now.Unix(),
+			"exp": now.Add(time.Hour).Unix(),
+			"ctx": []interface{}{"email"},
+			"cnf": map[string]interface{}{"jwk": testJwk(clientKey)},
+		},
+		popClaims: jwt.MapClaims{
+			"sub": "alice",
+			"aud": testAudience,
+			"iat": now.Unix(),
+			"exp": now.Add(time.Minute).Unix(),
+			"jti": "pop-1",
+		},
+	}
+}
+
+func (tokens *testTokens) verifier() IctVerifier {
+	return IctVerifier{
+		Issuer: testIssuer,
+		Keys: func(keyId string, alg jwt.SigningMethod) (crypto.PublicKey, error) {
+			if keyId != testKeyId {
+				return nil, errors.New("key '" + keyId + "' not found")
+			}
+			return tokens.issuerKey.Public(), nil
+		},
+	}
+}
+
+func TestIctVerifierVerify(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name    string
+		modify  func(t *testing.T, tokens *testTokens)
+		options IctVerificationOptions
+		wantErr bool
+	}{
+		{"valid", func(t *testing.T, tokens *testTokens) {}, IctVerificationOptions{}, false},
+		{"bad signature", func(t *testing.T, tokens *testTokens) {
+			tokens.issuerKey = newTestKey(t)
+		}, IctVerificationOptions{}, true},
+		{"wrong typ", func(t *testing.T, tokens *testTokens) {
+			tokens.ictHeader["typ"] = "JWT"
+		}, IctVerificationOptions{}, true},
+		{"wrong iss", func(t *testing.T, tokens *testTokens) {
+			tokens.ictClaims["iss"] = "https://attacker.example.com"
+		}, IctVerificationOptions{}, true},
+		{"expired ICT", func(t *testing.T, tokens *testTokens) {
+			tokens.ictClaims["iat"] = now.Add(-2 * time.Hour).Unix()
+			tokens.ictClaims["exp"] = now.Add(-time.Hour).Unix()
+		}, IctVerificationOptions{}, true},
+		{"mismatched cnf.jwk", func(t *testing.T, tokens *testTokens) {
+			tokens.ictClaims["cnf"] = map[string]interface{}{"jwk": testJwk(newTestKey(t))}
+		}, IctVerificationOptions{}, true},
+		{"missing context", func(t *testing.T, tokens *testTokens) {}, IctVerificationOptions{Contexts: []string{"phone"}}, true},
+		{"PoP with wrong aud", func(t *testing.T, tokens *testTokens) {
+			tokens.popClaims["aud"] = "mallory"
+		}, IctVerificationOptions{}, true},
+		{"PoP with wrong sub", func(t *testing.T, tokens *testTokens) {
+			tokens.popClaims["sub"] = "mallory"
+		}, IctVerificationOptions{}, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tokens := newTestTokens(t, now)
+			issuerKey := tokens.issuerKey
+			test.modify(t, tokens)
+			ict := signTestToken(t, tokens.issuerKey, tokens.ictHeader, tokens.ictClaims)
+			pop := signTestToken(t, tokens.clientKey, map[string]interface{}{"typ": "jwt+pop"}, tokens.popClaims)
+
+			// The verifier resolves the issuer's original key
+			tokens.issuerKey = issuerKey
+			options := test.options
+			options.Audience = testAudience
+			options.Now = now
+			verifiedIct, err := tokens.verifier().Verify(ict, pop, options)
+			if test.wantErr {
+				if err == nil {
+					t.Fatalf("Verify() = nil, want error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Verify() = %v, want nil", err)
+			}
+			if verifiedIct.Subject != "alice" || verifiedIct.PublicKey == nil || !verifiedIct.ExpiresAt.Equal(time.Unix(now.Add(time.Hour).Unix(), 0)) {
+				t.Errorf("Verify() = %+v, want verified ICT of 'alice'", verifiedIct)
+			}
+		})
+	}
+}
+
+func TestIctVerifierRejectsReplayedProofOfPossession(t *testing.T) {
+	now := time.Now()
+	tokens := newTestTokens(t, now)
+	ict := signTestToken(t, tokens.issuerKey, tokens.ictHeader, tokens.ictClaims)
+	pop := signTestToken(t, tokens.clientKey, map[string]interface{}{"typ": "jwt+pop"}, tokens.popClaims)
+
+	seen := map[string]time.Time{}
+	verifier := tokens.verifier()
+	verifier.ReplayCheck = func(jti string, expiresAt time.Time) error {
+		if _, ok := seen[jti]; ok {
+			return errors.New("token already used")
+		}
+		seen[jti] = expiresAt
+		return nil
+	}
+	options := IctVerificationOptions{Audience: testAudience, Now: now}
+
+	if _, err := verifier.Verify(ict, pop, options); err != nil {
+		t.Fatalf("first Verify() = %v, want nil", err)
+	}
+	if !seen["pop-1"].Equal(time.Unix(now.Add(time.Minute).Unix(), 0)) {
+		t.Errorf("replay check received expiration %v, want expiration of the PoP Token", seen["pop-1"])
+	}
+	if _, err := verifier.Verify(ict, pop, options); err == nil {
+		t.Fatalf("Verify() of replayed PoP Token = nil, want error")
+	}
+}